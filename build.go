@@ -1,14 +1,17 @@
 package wellington
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -26,6 +29,9 @@ type BuildArgs struct {
 	// paths are the initial directories passed to a build
 	// This is required to output files
 	paths []string
+	// manifest collects the entries written to Manifest, see
+	// ShareManifests
+	manifest *manifestStore
 
 	Payload  context.Context
 	ImageDir string
@@ -47,6 +53,52 @@ type BuildArgs struct {
 	SourceMap bool
 	// indicates the working directory which wt is run in
 	WorkDir string
+	// Manifest is the path of a JSON file describing the output of
+	// a Build, see Manifest. No manifest is written when empty. Builds
+	// writing the same manifest must share it, see ShareManifests.
+	Manifest string
+	// Cache is a directory recording the inputs of each compile, files
+	// whose inputs are unchanged are not compiled again. Requires
+	// BuildDir, no cache is kept when empty.
+	Cache string
+	// Header is the Sass registered with libsass.RegisterHeader, it
+	// is only used to invalidate the cache
+	Header string
+	// Jobs is the number of files compiled at once, defaults to
+	// GOMAXPROCS
+	Jobs int
+	// Progress receives a line for each file compiled, in the order
+	// the files were found. Nothing is reported when nil.
+	Progress io.Writer
+	// KeepGoing builds every file after a failure, Run returns all of
+	// the failures as Errors. Otherwise no files are started after the
+	// first failure.
+	KeepGoing bool
+	// Include and Exclude are glob patterns selecting the Sass files
+	// found in paths, see IgnoreFile
+	Include []string
+	Exclude []string
+	// Prune removes the outputs the last Build recorded in Cache or
+	// Manifest that a successful Build did not produce, see Prune
+	Prune bool
+	// Compress lists the formats, gzip or brotli, of the compressed
+	// copies written beside every CSS file and source map in BuildDir
+	Compress []string
+	// Processors are the names of registered Processors the CSS is
+	// passed through before it is written, in order
+	Processors []string
+	// Browsers is the query selecting the browsers processors like
+	// autoprefixer support ie. "last 2 versions, ie >= 11"
+	Browsers string
+	// Overrides change the options of the files they match, see
+	// Override for the directives files may also contain
+	Overrides []Override
+	// MaxSelectors splits CSS with more selectors into parts imported
+	// by the CSS, see DefaultMaxSelectors. CSS is not split when 0.
+	MaxSelectors int
+	// RTL writes a mirrored copy of each CSS file in BuildDir for right
+	// to left languages ie. main.rtl.css, see NoRTL
+	RTL bool
 }
 
 // Paths retrieves the paths in the arguments
@@ -75,6 +127,8 @@ func (b *BuildArgs) init() {
 type Build struct {
 	wg      sync.WaitGroup
 	closing chan struct{}
+	// abort stops queueing files after a failure
+	abort chan struct{}
 
 	workwg sync.WaitGroup
 	err    error
@@ -86,10 +140,27 @@ type Build struct {
 	paths      []string
 	bArgs      *BuildArgs
 	partialMap *SafePartialMap
+
+	mu    sync.Mutex
+	cache *buildCache
+	// files are reported in build order, see Report
+	files []FileReport
+	// pruned are the stale outputs removed, see BuildArgs.Prune
+	pruned []string
 }
 
 type work struct {
 	file string
+	// index is the position of file in the build order
+	index int
+}
+
+// result is the outcome of compiling a work item
+type result struct {
+	work
+	err error
+	dur time.Duration
+	FileReport
 }
 
 // NewBuild accepts arguments to reate a new Builder
@@ -104,6 +175,7 @@ func NewBuild(args *BuildArgs, pMap *SafePartialMap) *Build {
 
 		queue:   make(chan work),
 		closing: make(chan struct{}),
+		abort:   make(chan struct{}),
 
 		proj:       args.Project,
 		paths:      args.Paths(),
@@ -122,6 +194,21 @@ func (b *Build) Run() error {
 	if b.partialMap == nil {
 		return ErrPartialMap
 	}
+	if len(b.bArgs.Manifest) > 0 && len(b.bArgs.BuildDir) == 0 {
+		return ErrManifestBuildDir
+	}
+	if b.bArgs.Prune && len(b.bArgs.Cache) == 0 && len(b.bArgs.Manifest) == 0 {
+		return ErrPruneRecord
+	}
+	if err := checkCompress(b.bArgs.Compress); err != nil {
+		return err
+	}
+	if err := checkProcessors(b.bArgs.Processors); err != nil {
+		return err
+	}
+	if len(b.bArgs.Manifest) > 0 {
+		b.bArgs.beginManifest()
+	}
 
 	b.wg.Add(1)
 	go func() {
@@ -129,9 +216,65 @@ func (b *Build) Run() error {
 		b.doBuild()
 	}()
 
-	go b.loadWork()
+	err := <-b.done
+	if err != nil {
+		// files built before the failure are not rebuilt next time
+		if b.cache != nil {
+			b.saveCache(err)
+		}
+		return err
+	}
+	if b.bArgs.Prune {
+		b.pruned, err = Prune(b)
+		if err != nil {
+			return err
+		}
+	}
+	if b.cache != nil {
+		if err := b.saveCache(nil); err != nil {
+			return err
+		}
+	}
+	if len(b.bArgs.Manifest) == 0 {
+		return nil
+	}
+	return b.bArgs.writeManifest()
+}
+
+// saveCache writes the cache of the files built by Run with the sprites
+// they used. When the Build failed with buildErr, the entries of files
+// it did not reach are kept and the failed files are rebuilt next time.
+func (b *Build) saveCache(buildErr error) error {
+	sprites, err := b.sprites()
+	if buildErr == nil && err != nil {
+		return err
+	}
+	if buildErr != nil {
+		reported := make(map[string]bool)
+		b.mu.Lock()
+		for _, f := range b.files {
+			reported[absPath(f.Input)] = true
+		}
+		b.mu.Unlock()
+		b.cache.keep(reported)
+		if sprites == nil {
+			sprites = make(map[string]bool)
+		}
+		for _, out := range b.cache.prevSprites {
+			sprites[out] = true
+		}
+	}
+	b.cache.Sprites = make([]string, 0, len(sprites))
+	for sprite := range sprites {
+		b.cache.Sprites = append(b.cache.Sprites, sprite)
+	}
+	sort.Strings(b.cache.Sprites)
+	return b.cache.save()
+}
 
-	return <-b.done
+// Pruned returns the stale outputs removed by Run, see BuildArgs.Prune
+func (b *Build) Pruned() []string {
+	return b.pruned
 }
 
 // findFiles takes the input directories to locate files for building
@@ -147,49 +290,126 @@ func (b *Build) findFiles() ([]string, error) {
 		dirs = append(dirs, b.bArgs.WorkDir)
 	}
 	b.bArgs.paths = dirs
-	files := pathsToFiles(b.bArgs.paths, true)
+	files := pathsToFiles(b.bArgs.paths, true, b.bArgs.Include, b.bArgs.Exclude)
 	return files, nil
 }
 
-func (b *Build) loadWork() {
+// loadWork queues files for the workers
+func (b *Build) loadWork(files []string) {
+	defer close(b.queue)
+	for i, file := range files {
+		select {
+		case <-b.closing:
+			return
+		case <-b.abort:
+			return
+		case b.queue <- work{file: file, index: i}:
+		}
+	}
+}
+
+// doBuild compiles every file found with at most Jobs workers, the
+// result of report is sent to done
+func (b *Build) doBuild() {
 	files, err := b.findFiles()
+	if err == nil && len(b.bArgs.Cache) > 0 && len(b.bArgs.BuildDir) > 0 {
+		b.cache, err = loadCache(b.bArgs)
+	}
 	if err != nil {
 		b.done <- err
-		close(b.queue)
 		return
 	}
 
-	for _, file := range files {
-		b.queue <- work{file: file}
+	go b.loadWork(files)
+
+	jobs := b.bArgs.Jobs
+	if jobs < 1 {
+		jobs = runtime.GOMAXPROCS(0)
 	}
-	close(b.queue)
+	results := make(chan result)
+	for i := 0; i < jobs; i++ {
+		b.workwg.Add(1)
+		go b.worker(results)
+	}
+	go func() {
+		b.workwg.Wait()
+		close(results)
+	}()
+
+	b.done <- b.report(len(files), results)
 }
 
-func (b *Build) doBuild() {
+// worker compiles files from the queue until it is empty or the Build
+// is closed
+func (b *Build) worker(results chan<- result) {
+	defer b.workwg.Done()
 	for {
 		select {
 		case <-b.closing:
 			return
-		case work := <-b.queue:
-			path := work.file
-			if len(path) == 0 {
-				b.workwg.Wait()
-				b.done <- nil
+		case w, ok := <-b.queue:
+			if !ok {
 				return
 			}
-			b.workwg.Add(1)
-			go func(path string) {
-				err := b.build(path)
-				defer b.workwg.Done()
-				if err != nil {
-					b.done <- err
+			r := result{work: w}
+			r.Input = w.file
+			start := time.Now()
+			r.err = b.build(w.file, &r.FileReport)
+			r.dur = time.Since(start)
+			results <- r
+		}
+	}
+}
+
+// report collects results, writing progress in build order. Results
+// finishing early are held until the files before them are done.
+// The first failure is returned, or all of them with KeepGoing.
+func (b *Build) report(total int, results <-chan result) error {
+	var errs Errors
+	pending := make(map[int]result)
+	next := 0
+	for r := range results {
+		pending[r.index] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			r.Duration = r.dur.Seconds()
+			if r.err != nil {
+				r.Error = newFileError(r.file, r.err)
+				errs = append(errs, r.Error)
+				if len(errs) == 1 && !b.bArgs.KeepGoing {
+					close(b.abort)
 				}
-			}(path)
+			}
+			b.mu.Lock()
+			b.files = append(b.files, r.FileReport)
+			b.mu.Unlock()
+			if b.bArgs.Progress == nil {
+				continue
+			}
+			status := r.dur.String()
+			if r.err != nil {
+				status = "failed"
+			}
+			fmt.Fprintf(b.bArgs.Progress, "[%d/%d] %s %s\n",
+				next, total, r.file, status)
 		}
 	}
+	if len(errs) == 0 {
+		return nil
+	}
+	if b.bArgs.KeepGoing {
+		return errs
+	}
+	return errs[0]
 }
 
-func (b *Build) build(path string) error {
+// build compiles path, recording the outcome in fr
+func (b *Build) build(path string, fr *FileReport) error {
 	if len(path) == 0 {
 		return errors.New("invalid path given")
 	}
@@ -198,12 +418,74 @@ func (b *Build) build(path string) error {
 		return errors.New("file does not end in .sass or .scss")
 	}
 
-	out, sout, bdir, err := b.bArgs.getOut(path)
+	if b.cache != nil {
+		if e, ok := b.cache.fresh(path); ok {
+			fr.Output, fr.Parts, fr.RTL = e.Output, e.Parts, e.RTL
+			fr.Cached = true
+			return b.skip(path, e)
+		}
+	}
+
+	fa, err := b.bArgs.fileArgs(path)
+	if err != nil {
+		return err
+	}
+	out, sout, bdir, err := fa.getOut(path)
 	if err != nil {
 		return err
 	}
 
-	return loadAndBuild(path, b.bArgs, b.partialMap, out, sout, bdir)
+	// Warnings are collected for each file
+	gba := *fa
+	gba.Payload = payload.WithWarnings(b.bArgs.Payload)
+	err = loadAndBuild(path, &gba, b.partialMap, out, sout, bdir)
+	fr.Warnings = payload.Warnings(gba.Payload).List()
+	if err != nil {
+		return err
+	}
+
+	name, parts, rtl, err := fa.finishOutput(path)
+	if err != nil {
+		return err
+	}
+	if len(bdir) > 0 {
+		fr.Output, fr.Parts, fr.RTL = name, parts, rtl
+		if info, err := os.Stat(name); err == nil {
+			fr.Bytes = info.Size()
+		}
+	}
+	if b.cache != nil {
+		err = b.cache.update(path, name, rtl, parts,
+			b.partialMap.importsOf(path), b.partialMap.assetsOf(path))
+		if err != nil {
+			return err
+		}
+	}
+	if len(b.bArgs.Manifest) == 0 {
+		return nil
+	}
+	return b.bArgs.record(path, name, rtl, parts, b.partialMap.importsOf(path), fa)
+}
+
+// skip uses the cached compile of path, its imports are added to the
+// partial map as if it had been compiled
+func (b *Build) skip(path string, e *cacheEntry) error {
+	imports := make([]string, 0, len(e.Inputs))
+	for in := range e.Inputs {
+		imports = append(imports, in)
+	}
+	b.partialMap.setImports(path, imports)
+	for asset := range e.Assets {
+		b.partialMap.AddAsset(path, asset)
+	}
+	if len(b.bArgs.Manifest) == 0 {
+		return nil
+	}
+	fa, err := b.bArgs.fileArgs(path)
+	if err != nil {
+		return err
+	}
+	return b.bArgs.record(path, e.Output, e.RTL, e.Parts, b.partialMap.importsOf(path), fa)
 }
 
 // Close shuts down the builder ensuring all go routines have properly
@@ -216,6 +498,71 @@ func (b *Build) Close() error {
 
 var inputFileTypes = []string{".scss", ".sass"}
 
+// finishOutput writes the files derived from the CSS built from path:
+// its RTL copy, the parts of CSS over MaxSelectors, content hashed names
+// and compressed copies. The final names of the CSS, the parts of it and
+// its RTL copy, and the RTL copy are returned.
+func (b *BuildArgs) finishOutput(path string) (string, []string, string, error) {
+	rtl, err := b.rtlOutput(path)
+	if err != nil {
+		return "", nil, "", err
+	}
+	parts, err := b.splitFile(b.outPath(path))
+	if err != nil {
+		return "", nil, "", err
+	}
+	if len(rtl) > 0 {
+		rparts, err := b.splitFile(rtl)
+		if err != nil {
+			return "", nil, "", err
+		}
+		parts = append(parts, rparts...)
+		if b.CacheBust == "filename" {
+			if rtl, err = hashName(rtl); err != nil {
+				return "", nil, "", err
+			}
+		}
+	}
+	name, err := b.hashOutput(path)
+	if err != nil {
+		return "", nil, "", err
+	}
+	copies := parts
+	if len(rtl) > 0 {
+		copies = append(append([]string{}, parts...), rtl)
+	}
+	return name, parts, rtl, b.compressOutput(path, name, copies)
+}
+
+// hashOutput renames the CSS built from path to include a hash of its
+// contents ie. file.1a2b3c4d.css when cache busting by file name.
+// The final location of the CSS is returned.
+func (b *BuildArgs) hashOutput(path string) (string, error) {
+	name := b.outPath(path)
+	if b.CacheBust != "filename" || len(b.BuildDir) == 0 {
+		return name, nil
+	}
+	return hashName(name)
+}
+
+// hashName renames the file at name to include a hash of its contents
+func hashName(name string) (string, error) {
+	hash, _, err := hashFile(name)
+	if err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(name)
+	hashed := strings.TrimSuffix(name, ext) + "." + hash[:8] + ext
+	return hashed, os.Rename(name, hashed)
+}
+
+// outPath returns the location of the CSS file built from path
+func (b *BuildArgs) outPath(path string) string {
+	rel := relative(b.paths, path)
+	filename := updateFileOutputType(filepath.Base(path))
+	return filepath.Join(b.BuildDir, rel, filename)
+}
+
 func (b *BuildArgs) getOut(path string) (io.WriteCloser, string, string, error) {
 
 	var (
@@ -228,9 +575,7 @@ func (b *BuildArgs) getOut(path string) (io.WriteCloser, string, string, error)
 		out = os.Stdout
 		return out, "", "", nil
 	}
-	rel := relative(b.paths, path)
-	filename := updateFileOutputType(filepath.Base(path))
-	name := filepath.Join(b.BuildDir, rel, filename)
+	name := b.outPath(path)
 	dir := filepath.Dir(name)
 	// FIXME: do this once per Build instead of every file
 	err := os.MkdirAll(dir, 0755)
@@ -238,48 +583,62 @@ func (b *BuildArgs) getOut(path string) (io.WriteCloser, string, string, error)
 		return nil, "", "", fmt.Errorf("Failed to create directory: %s",
 			dir)
 	}
-	out, err = os.Create(name)
+	o, err := createOutput(name, b.SourceMap)
 	if err != nil {
 		return nil, "", "", err
 	}
-	var smap string
-	if b.SourceMap {
-		smap = name + ".map"
-	}
-
-	return out, smap, dir, err
+	return o, o.smap, dir, nil
 }
 
 // LoadAndBuild kicks off parser and compiling. It expands directories
 // to recursively locate Sass files
 // TODO: make this function testable
 func LoadAndBuild(path string, gba *BuildArgs, pMap *SafePartialMap) error {
+	_, err := loadAndBuildFile(path, gba, pMap)
+	return err
+}
+
+// loadAndBuildFile is LoadAndBuild returning the final names of the CSS
+// written: the CSS, its split parts and its RTL copy. None are returned
+// when the CSS is written to stdout. The CSS replaces the entry of path
+// in the manifest of a Build run with gba, see updateManifest.
+func loadAndBuildFile(path string, gba *BuildArgs, pMap *SafePartialMap) ([]string, error) {
 	if len(path) == 0 {
-		return errors.New("invalid path passed")
+		return nil, errors.New("invalid path passed")
 	}
-
-	// file detected!
-	if isImportable(path) {
-		out, sout, bdir, err := gba.getOut(path)
-		if err != nil {
-			return err
-		}
-		return loadAndBuild(path, gba, pMap, out, sout, bdir)
+	fa, err := gba.fileArgs(path)
+	if err != nil {
+		return nil, err
 	}
 
-	out, sout, bdir, err := gba.getOut(path)
+	out, sout, bdir, err := fa.getOut(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = loadAndBuild(path, gba, pMap, out, sout, bdir)
+	err = loadAndBuild(path, fa, pMap, out, sout, bdir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	name, parts, rtl, err := fa.finishOutput(path)
+	if err != nil || len(bdir) == 0 {
+		return nil, err
+	}
+	if len(gba.Manifest) > 0 && gba.manifest != nil {
+		err = gba.record(path, name, rtl, parts, pMap.importsOf(path), fa)
+	}
+	if err != nil {
+		return nil, err
+	}
+	outs := append([]string{name}, parts...)
+	if len(rtl) > 0 {
+		outs = append(outs, rtl)
+	}
+	return outs, nil
 }
 
-// FromBuildArgs creates a compiler from BuildArgs
+// FromBuildArgs creates a compiler from BuildArgs. The CSS is passed
+// through the Processors before it is written to dst.
 func FromBuildArgs(dst io.Writer, dstmap string, src io.Reader, gba *BuildArgs) (libsass.Compiler, error) {
 	if gba == nil {
 		return libsass.New(dst, src)
@@ -287,6 +646,14 @@ func FromBuildArgs(dst io.Writer, dstmap string, src io.Reader, gba *BuildArgs)
 	if gba.Payload == nil {
 		gba.init()
 	}
+	if err := checkProcessors(gba.Processors); err != nil {
+		return nil, err
+	}
+	var pc *processCompiler
+	if len(gba.Processors) > 0 {
+		pc = &processCompiler{dst: dst, buf: &bytes.Buffer{}, gba: gba}
+		dst = pc.buf
+	}
 
 	comp, err := libsass.New(dst, src,
 		// Options overriding defaults
@@ -302,20 +669,34 @@ func FromBuildArgs(dst io.Writer, dstmap string, src io.Reader, gba *BuildArgs)
 		libsass.CacheBust(gba.CacheBust),
 		libsass.SourceMap(gba.SourceMap, dstmap, ""),
 	)
-	return comp, err
+	if err != nil || pc == nil {
+		return comp, err
+	}
+	pc.Compiler = comp
+	return pc, nil
 }
 
-func loadAndBuild(sassFile string, gba *BuildArgs, partialMap *SafePartialMap, out io.WriteCloser, srcmap string, buildDir string) error {
+func loadAndBuild(sassFile string, gba *BuildArgs, partialMap *SafePartialMap, out io.WriteCloser, srcmap string, buildDir string) (err error) {
 	defer func() {
 		// BuildDir lets us know if we should closer out. If no buildDir,
 		// specified out == os.Stdout and do not close. If buildDir != "",
 		// then out must be something we should close.
 		// This is important, since out can be many things and inspecting
 		// them could be race unsafe.
-		if len(buildDir) > 0 {
+		if len(buildDir) == 0 {
+			return
+		}
+		o, ok := out.(*output)
+		if !ok {
 			out.Close()
-			// sout.Close()
+			return
+		}
+		// Only replace the last output once this one is complete
+		if err != nil {
+			o.Abort()
+			return
 		}
+		err = o.Commit()
 	}()
 
 	// FIXME: move this elsewhere or make it so it doesn't need to be set
@@ -324,17 +705,37 @@ func loadAndBuild(sassFile string, gba *BuildArgs, partialMap *SafePartialMap, o
 		imgdir = filepath.Dir(sassFile)
 	}
 
-	comp, err := libsass.New(out, nil,
+	// libsass locates the source map relative to the file written
+	var dst io.Writer = out
+	o, isOutput := out.(*output)
+	if isOutput {
+		dst = o.File
+	}
+	// other writers are buffered for the Processors
+	var buf *bytes.Buffer
+	if len(gba.Processors) > 0 && !isOutput {
+		buf = &bytes.Buffer{}
+		dst = buf
+	}
+
+	// the assets used are collected for the file watcher
+	ctx := gba.Payload
+	if ctx != nil {
+		ctx = payload.WithAssets(ctx)
+	}
+
+	comp, err := libsass.New(dst, nil,
 		// Options overriding defaults
 		libsass.Path(sassFile),
 		libsass.ImgDir(imgdir),
 		libsass.BuildDir(buildDir),
-		libsass.Payload(gba.Payload),
+		libsass.Payload(ctx),
 		libsass.Comments(gba.Comments),
 		libsass.OutputStyle(gba.Style),
 		libsass.FontDir(gba.Font),
 		libsass.ImgBuildDir(gba.Gen),
 		libsass.IncludePaths(gba.Includes),
+		libsass.CacheBust(gba.CacheBust),
 		libsass.SourceMap(gba.SourceMap, srcmap, ""),
 	)
 
@@ -344,11 +745,33 @@ func loadAndBuild(sassFile string, gba *BuildArgs, partialMap *SafePartialMap, o
 
 	// Start Sass transformation
 	err = comp.Run()
+	// a missing image may be the failure, so assets are recorded anyway
+	if ctx != nil {
+		var assets []string
+		for _, asset := range payload.Assets(ctx).List() {
+			assets = append(assets, absPath(asset))
+		}
+		partialMap.setAssets(sassFile, assets)
+	}
 	if err != nil {
-		return errors.New(color.RedString("%s", err))
+		fe := newFileError(sassFile, err)
+		fe.err = errors.New(color.RedString("%s", err))
+		return fe
 	}
-	for _, inc := range comp.Imports() {
-		partialMap.AddRelation(sassFile, inc)
+	partialMap.setImports(sassFile, comp.Imports())
+
+	if len(gba.Processors) > 0 {
+		if isOutput {
+			err = o.process(sassFile, gba)
+		} else {
+			s := &Stylesheet{Input: sassFile, CSS: buf.Bytes(), Args: gba}
+			if err = gba.process(s); err == nil {
+				_, err = out.Write(s.CSS)
+			}
+		}
+		if err != nil {
+			return newFileError(sassFile, err)
+		}
 	}
 
 	// TODO: moves this method to *Build and wait on it to finish
@@ -2,6 +2,7 @@ package wellington
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -221,6 +222,51 @@ func TestNewBuild_two(t *testing.T) {
 	}
 }
 
+func TestBuild_progress(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"a.scss", "b.scss", "c.scss", "d.scss"}
+	for _, name := range names {
+		err := ioutil.WriteFile(filepath.Join(sdir, name),
+			[]byte("div { color: red; }"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var progress bytes.Buffer
+	args := &BuildArgs{
+		BuildDir: filepath.Join(tdir, "build"),
+		Jobs:     2,
+		Progress: &progress,
+	}
+	args.WithPaths([]string{sdir})
+	err = NewBuild(args, NewPartialMap()).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(progress.String()), "\n")
+	if len(lines) != len(names) {
+		t.Fatalf("got: %d wanted: %d\n%s", len(lines), len(names), progress.String())
+	}
+	for i, name := range names {
+		prefix := fmt.Sprintf("[%d/%d] %s ", i+1, len(names),
+			filepath.Join(sdir, name))
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("got: %s wanted prefix: %s", lines[i], prefix)
+		}
+	}
+}
+
 func TestNewBuild_dir(t *testing.T) {
 	tdir, _ := ioutil.TempDir("", "testnewbuild_two")
 	ps := []string{"test/sass"}
@@ -366,3 +412,65 @@ func TestUpdateFileOutputType(t *testing.T) {
 		t.Errorf("got: %s wanted: %s", ren, e)
 	}
 }
+
+func TestBuild_hashedDirs(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_hasheddirs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	idir := filepath.Join(tdir, "img")
+	bdir := filepath.Join(tdir, "build")
+	for _, dir := range []string{filepath.Join(sdir, "sub"), idir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scss := []byte(`div { background: image-url("logo.png"); }`)
+	for _, name := range []string{"a.scss", filepath.Join("sub", "b.scss")} {
+		if err := ioutil.WriteFile(filepath.Join(sdir, name), scss, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(idir, "logo.png"), []byte("logo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := &BuildArgs{
+		BuildDir:  bdir,
+		ImageDir:  idir,
+		CacheBust: "filename",
+	}
+	args.WithPaths([]string{sdir})
+	// Both stylesheets keep a copy next to them, neither removes the
+	// copy of the other
+	for i := 0; i < 2; i++ {
+		if err := NewBuild(args, NewPartialMap()).Run(); err != nil {
+			t.Fatal(err)
+		}
+		for _, css := range []string{"a.*.css", filepath.Join("sub", "b.*.css")} {
+			matches, err := filepath.Glob(filepath.Join(bdir, css))
+			if err != nil || len(matches) != 1 {
+				t.Fatalf("got: %v %v wanted one %s", matches, err, css)
+			}
+			path := matches[0]
+			bs, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := string(bs)
+			start := strings.Index(s, "url('")
+			end := strings.Index(s, "')")
+			if start < 0 || end < start {
+				t.Fatalf("no url in %s: %s", css, s)
+			}
+			url := s[start+len("url('") : end]
+			img := filepath.Join(filepath.Dir(path), filepath.FromSlash(url))
+			if _, err := os.Stat(img); err != nil {
+				t.Errorf("%s: %s", css, err)
+			}
+		}
+	}
+}
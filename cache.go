@@ -0,0 +1,286 @@
+package wellington
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/wellington/wellington/version"
+)
+
+// buildCache records the inputs of every file compiled by a Build, so
+// the next Build can skip files whose inputs have not changed.
+type buildCache struct {
+	path string
+
+	mu   sync.Mutex
+	sums map[string]string
+	// prev holds the entries read from disk, only files seen by this
+	// Build are written back
+	prev map[string]*cacheEntry
+	// prevSprites are the sprites recorded by the last Build
+	prevSprites []string
+	// recorded are the outputs and sprites recorded by the last Build
+	// whatever its key, see Prune
+	recorded []string
+
+	// Key is a hash of the build options, any change to them
+	// invalidates all entries
+	Key   string                 `json:"key"`
+	Files map[string]*cacheEntry `json:"files"`
+	// Sprites are every sprite used by the files, skipped files do not
+	// request their sprites again
+	Sprites []string `json:"sprites"`
+}
+
+// cacheEntry is the last successful compile of a top level file
+type cacheEntry struct {
+	Output string   `json:"output"`
+	Parts  []string `json:"parts,omitempty"`
+	RTL    string   `json:"rtl,omitempty"`
+	// Inputs maps the file and all of its imports to their sha1
+	Inputs map[string]string `json:"inputs"`
+	// Assets maps the images, fonts and sprite globs used to their
+	// sha1, see assetSum
+	Assets map[string]string `json:"assets,omitempty"`
+}
+
+// cachePath returns the file in the cache directory used by these
+// arguments. Builds writing to different places do not share entries.
+func (b *BuildArgs) cachePath() string {
+	h := sha1.New()
+	fmt.Fprintln(h, b.BuildDir)
+	for _, path := range b.paths {
+		fmt.Fprintln(h, path)
+	}
+	return filepath.Join(b.Cache, fmt.Sprintf("build-%x.json", h.Sum(nil)[:4]))
+}
+
+// cacheKey hashes the options that change the output of a compile.
+// The Sass files, images and fonts used are checked by each entry.
+func (b *BuildArgs) cacheKey() (string, error) {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\n%d\n%t\n%s\n%t\n%s\n%s\n%s\n%q\n%s\n%q\n%q\n%s\n%d\n%t\n",
+		version.Version, b.Style, b.Comments, b.CacheBust, b.SourceMap,
+		b.ImageDir, b.Font, b.Gen, b.Includes, b.Header, b.Compress,
+		b.Processors, b.Browsers, b.MaxSelectors, b.RTL)
+	// Directives in a file change its sum instead
+	for _, o := range b.Overrides {
+		bs, err := json.Marshal(o)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n", bs)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// loadCache reads the cache for gba. A missing, corrupt or outdated
+// cache results in an empty one.
+func loadCache(gba *BuildArgs) (*buildCache, error) {
+	key, err := gba.cacheKey()
+	if err != nil {
+		return nil, err
+	}
+	c := &buildCache{
+		path:  gba.cachePath(),
+		sums:  make(map[string]string),
+		prev:  make(map[string]*cacheEntry),
+		Key:   key,
+		Files: make(map[string]*cacheEntry),
+	}
+
+	bs, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var old buildCache
+	if err := json.Unmarshal(bs, &old); err != nil {
+		return c, nil
+	}
+	for _, e := range old.Files {
+		c.recorded = append(c.recorded, e.Output)
+		c.recorded = append(c.recorded, e.Parts...)
+		if len(e.RTL) > 0 {
+			c.recorded = append(c.recorded, e.RTL)
+		}
+	}
+	c.recorded = append(c.recorded, old.Sprites...)
+	if old.Key == key && old.Files != nil {
+		c.prev = old.Files
+		c.prevSprites = old.Sprites
+	}
+	return c, nil
+}
+
+// sum returns the sha1 of path, each file is read once per Build
+func (c *buildCache) sum(path string) (string, error) {
+	c.mu.Lock()
+	sum, ok := c.sums[path]
+	c.mu.Unlock()
+	if ok {
+		return sum, nil
+	}
+	sum, _, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.sums[path] = sum
+	c.mu.Unlock()
+	return sum, nil
+}
+
+// assetSum returns the sha1 of the asset at path. The sum of a sprite
+// glob covers the names and sums of every file it matches. Missing
+// files have an empty sum.
+func (c *buildCache) assetSum(path string) (string, error) {
+	if !strings.ContainsAny(path, "*?[") {
+		sum, err := c.sum(path)
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return sum, err
+	}
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	for _, match := range matches {
+		sum, err := c.sum(match)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintln(h, match, sum)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// fresh returns the entry for path if its output exists and none of
+// its inputs and assets have changed since it was compiled
+func (c *buildCache) fresh(path string) (*cacheEntry, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	e, ok := c.prev[abs]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	outs := append([]string{e.Output}, e.Parts...)
+	if len(e.RTL) > 0 {
+		outs = append(outs, e.RTL)
+	}
+	for _, out := range outs {
+		if _, err := os.Stat(out); err != nil {
+			return nil, false
+		}
+	}
+	for in, sum := range e.Inputs {
+		cur, err := c.sum(in)
+		if err != nil || cur != sum {
+			return nil, false
+		}
+	}
+	for asset, sum := range e.Assets {
+		cur, err := c.assetSum(asset)
+		if err != nil || cur != sum {
+			return nil, false
+		}
+	}
+	c.mu.Lock()
+	c.Files[abs] = e
+	c.mu.Unlock()
+	return e, true
+}
+
+// update records that path was compiled to out and rtl, split into
+// parts, from imports using assets
+func (c *buildCache) update(path, out, rtl string, parts, imports, assets []string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	out, err = filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	e := &cacheEntry{
+		Output: out,
+		Inputs: make(map[string]string),
+	}
+	for _, asset := range assets {
+		sum, err := c.assetSum(asset)
+		if err != nil {
+			return err
+		}
+		if e.Assets == nil {
+			e.Assets = make(map[string]string)
+		}
+		e.Assets[asset] = sum
+	}
+	for _, part := range parts {
+		abs, err := filepath.Abs(part)
+		if err != nil {
+			return err
+		}
+		e.Parts = append(e.Parts, abs)
+	}
+	if len(rtl) > 0 {
+		if e.RTL, err = filepath.Abs(rtl); err != nil {
+			return err
+		}
+	}
+	for _, in := range append([]string{abs}, imports...) {
+		// Builtin imports ie. compass are not files
+		if !filepath.IsAbs(in) {
+			continue
+		}
+		sum, err := c.sum(in)
+		if err != nil {
+			return err
+		}
+		e.Inputs[in] = sum
+	}
+	c.mu.Lock()
+	c.Files[abs] = e
+	c.mu.Unlock()
+	return nil
+}
+
+// keep carries the entries of the files a failed Build did not reach
+// over from the last Build, the files that failed are left out
+func (c *buildCache) keep(reported map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, e := range c.prev {
+		if _, ok := c.Files[path]; !ok && !reported[path] {
+			c.Files[path] = e
+		}
+	}
+}
+
+// save writes the entries of every file seen by the Build
+func (c *buildCache) save() error {
+	c.mu.Lock()
+	bs, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(bs, '\n'), 0644)
+}
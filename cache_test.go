@@ -0,0 +1,172 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuild_cache(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	src := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	partial := filepath.Join(src, "_vars.scss")
+	write := func(path, contents string) {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(partial, "$color: red;\n")
+	write(filepath.Join(src, "main.scss"), "@import \"vars\";\ndiv { color: $color; }\n")
+
+	bdir := filepath.Join(tdir, "build")
+	out := filepath.Join(bdir, "main.css")
+	build := func() string {
+		args := &BuildArgs{
+			BuildDir: bdir,
+			Cache:    filepath.Join(tdir, ".wt-cache"),
+		}
+		args.WithPaths([]string{src})
+		if err := NewBuild(args, NewPartialMap()).Run(); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bs)
+	}
+
+	if css := build(); !strings.Contains(css, "red") {
+		t.Fatalf("got: %s wanted: red", css)
+	}
+
+	// Unchanged inputs must not be compiled again
+	write(out, "cached")
+	if css := build(); css != "cached" {
+		t.Errorf("got: %s wanted: cached", css)
+	}
+
+	write(partial, "$color: blue;\n")
+	if css := build(); !strings.Contains(css, "blue") {
+		t.Errorf("got: %s wanted: blue", css)
+	}
+}
+
+func TestBuild_cacheAssets(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_cacheassets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	src := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path, contents string) {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(src, "main.scss"), `div { background: image-url("logo.png"); }`)
+	logo := filepath.Join(tdir, "logo.png")
+	write(logo, "one")
+
+	// The image directory holds the build and cache, as it does by
+	// default from the command line
+	cached := func() bool {
+		args := &BuildArgs{
+			BuildDir:  filepath.Join(tdir, "build"),
+			ImageDir:  tdir,
+			Font:      tdir,
+			CacheBust: "sum",
+			Cache:     filepath.Join(tdir, ".wt-cache"),
+		}
+		args.WithPaths([]string{src})
+		b := NewBuild(args, NewPartialMap())
+		if err := b.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return b.Report().Files[0].Cached
+	}
+
+	if cached() {
+		t.Fatal("first build was cached")
+	}
+	write(filepath.Join(tdir, "unrelated.txt"), "unused")
+	if !cached() {
+		t.Error("files not used by the build invalidated the cache")
+	}
+	write(logo, "two")
+	if cached() {
+		t.Error("changed image did not invalidate the cache")
+	}
+}
+
+func TestBuild_cacheKeepGoing(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_cachekeepgoing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	src := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path, contents string) {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bad := filepath.Join(src, "b.scss")
+	write(filepath.Join(src, "a.scss"), "div { color: red; }\n")
+	write(bad, "div { color: $missing; }\n")
+
+	bdir := filepath.Join(tdir, "build")
+	out := filepath.Join(bdir, "a.css")
+	build := func() (*Build, error) {
+		args := &BuildArgs{
+			BuildDir:  bdir,
+			Cache:     filepath.Join(tdir, ".wt-cache"),
+			KeepGoing: true,
+		}
+		args.WithPaths([]string{src})
+		b := NewBuild(args, NewPartialMap())
+		return b, b.Run()
+	}
+
+	if _, err := build(); err == nil {
+		t.Fatal("no error building b.scss")
+	}
+
+	// a.scss compiled while b.scss failed, it is not compiled again
+	write(out, "cached")
+	write(bad, "div { color: blue; }\n")
+	b, err := build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "cached" {
+		t.Errorf("got: %s wanted: cached", bs)
+	}
+	for _, f := range b.Report().Files {
+		if e := f.Input == filepath.Join(src, "a.scss"); f.Cached != e {
+			t.Errorf("%s got cached: %t wanted: %t", f.Input, f.Cached, e)
+		}
+	}
+}
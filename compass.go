@@ -0,0 +1,199 @@
+package wellington
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var reCompassAssign = regexp.MustCompile(`^([a-z_]+)\s*=\s*(.+)$`)
+
+// compassPaths maps Compass directory settings onto Config fields
+var compassPaths = map[string]func(*Config) *string{
+	"css_dir":               func(c *Config) *string { return &c.BuildDir },
+	"css_path":              func(c *Config) *string { return &c.BuildDir },
+	"images_dir":            func(c *Config) *string { return &c.ImageDir },
+	"images_path":           func(c *Config) *string { return &c.ImageDir },
+	"generated_images_dir":  func(c *Config) *string { return &c.Gen },
+	"generated_images_path": func(c *Config) *string { return &c.Gen },
+	"fonts_dir":             func(c *Config) *string { return &c.Font },
+	"fonts_path":            func(c *Config) *string { return &c.Font },
+	"sass_dir":              func(c *Config) *string { return &c.Project },
+	"sass_path":             func(c *Config) *string { return &c.Project },
+	"http_path":             func(c *Config) *string { return &c.HTTPPath },
+}
+
+// compassIgnored are settings that have no equivalent in wt
+var compassIgnored = map[string]bool{
+	"javascripts_dir":  true,
+	"javascripts_path": true,
+	"preferred_syntax": true,
+	"environment":      true,
+	"cache":            true,
+	"cache_path":       true,
+	"disable_warnings": true,
+
+	"http_generated_images_path": true,
+}
+
+// ReadCompassConfig interprets the common subset of a Compass
+// config.rb: assignments of strings, symbols and booleans. Lines that
+// can not be interpreted are recorded in Config.Warnings.
+func ReadCompassConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{}
+	var project string
+	// dirs holds the fields set by *_dir settings
+	dirs := make(map[*string]string)
+
+	scanner := bufio.NewScanner(r)
+	var n int
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(stripRubyComment(scanner.Text()))
+		if len(line) == 0 {
+			continue
+		}
+		warn := func(format string, args ...interface{}) {
+			cfg.Warnings = append(cfg.Warnings,
+				fmt.Sprintf("line %d: ", n)+fmt.Sprintf(format, args...))
+		}
+
+		m := reCompassAssign.FindStringSubmatch(line)
+		if m == nil {
+			warn("can not interpret: %s", line)
+			continue
+		}
+		key := m[1]
+		val, ok := rubyValue(m[2])
+		if !ok {
+			warn("unsupported value for %s: %s", key, m[2])
+			continue
+		}
+
+		switch key {
+		case "project_path":
+			if s, ok := val.(string); ok {
+				project = s
+				continue
+			}
+		case "output_style":
+			if s, ok := val.(string); ok {
+				cfg.Style = s
+				continue
+			}
+		case "line_comments":
+			if b, ok := val.(bool); ok {
+				cfg.Comments = b
+				continue
+			}
+		case "sourcemap":
+			if b, ok := val.(bool); ok {
+				cfg.SourceMap = b
+				continue
+			}
+		case "relative_assets":
+			if b, ok := val.(bool); ok {
+				if !b {
+					warn("relative_assets = false is not supported, asset urls are always relative")
+				}
+				continue
+			}
+		default:
+			if compassIgnored[key] {
+				warn("%s is not supported and was ignored", key)
+				continue
+			}
+			field, known := compassPaths[key]
+			if !known {
+				warn("unknown setting: %s", key)
+				continue
+			}
+			if s, ok := val.(string); ok {
+				f := field(cfg)
+				*f = s
+				delete(dirs, f)
+				if strings.HasSuffix(key, "_dir") {
+					dirs[f] = s
+				}
+				continue
+			}
+		}
+		warn("unsupported value for %s: %s", key, m[2])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Compass resolves directories against project_path, *_path
+	// settings are already complete paths.
+	if len(project) > 0 {
+		for f, val := range dirs {
+			if !filepath.IsAbs(val) {
+				*f = filepath.Join(project, val)
+			}
+		}
+	}
+
+	if len(cfg.Project) > 0 {
+		cfg.Paths = []string{cfg.Project}
+		cfg.Project = ""
+	}
+	return cfg, nil
+}
+
+// rubyValue converts a literal Ruby string, symbol or boolean
+func rubyValue(s string) (interface{}, bool) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "true":
+		return true, true
+	case s == "false":
+		return false, true
+	case len(s) > 1 && s[0] == ':':
+		sym := s[1:]
+		if strings.IndexFunc(sym, func(r rune) bool {
+			return !(r == '_' || r >= 'a' && r <= 'z' ||
+				r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) >= 0 {
+			return nil, false
+		}
+		return sym, true
+	case len(s) > 1 && (s[0] == '"' || s[0] == '\''):
+		quote := s[0]
+		if s[len(s)-1] != quote {
+			return nil, false
+		}
+		str := s[1 : len(s)-1]
+		if strings.IndexByte(str, quote) >= 0 {
+			return nil, false
+		}
+		// Interpolation requires evaluating Ruby
+		if quote == '"' && strings.Contains(str, "#{") {
+			return nil, false
+		}
+		return str, true
+	}
+	return nil, false
+}
+
+// stripRubyComment removes trailing # comments that are not inside
+// of a string
+func stripRubyComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,157 @@
+package wellington
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadCompassConfig(t *testing.T) {
+	in := bytes.NewBufferString(`# Require any additional compass plugins here.
+require 'susy'
+
+http_path = "/"
+css_dir = "stylesheets" # trailing comment
+sass_dir = 'sass'
+images_dir = "images"
+fonts_dir = "fonts"
+output_style = :compressed
+relative_assets = true
+line_comments = false
+javascripts_dir = "javascripts"
+output_style = (environment == :production) ? :compressed : :expanded
+`)
+	cfg, err := ReadConfig(in, ".rb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := "stylesheets"; cfg.BuildDir != e {
+		t.Errorf("got: %s wanted: %s", cfg.BuildDir, e)
+	}
+	if e := "images"; cfg.ImageDir != e {
+		t.Errorf("got: %s wanted: %s", cfg.ImageDir, e)
+	}
+	if e := "fonts"; cfg.Font != e {
+		t.Errorf("got: %s wanted: %s", cfg.Font, e)
+	}
+	if e := "/"; cfg.HTTPPath != e {
+		t.Errorf("got: %s wanted: %s", cfg.HTTPPath, e)
+	}
+	if e := "compressed"; cfg.Style != e {
+		t.Errorf("got: %s wanted: %s", cfg.Style, e)
+	}
+	if len(cfg.Paths) != 1 || cfg.Paths[0] != "sass" {
+		t.Errorf("got: %v wanted: [sass]", cfg.Paths)
+	}
+
+	// require, javascripts_dir and the ternary
+	if e := 3; len(cfg.Warnings) != e {
+		t.Errorf("got: %d wanted: %d\n%v", len(cfg.Warnings), e, cfg.Warnings)
+	}
+}
+
+func TestReadCompassConfig_projectPath(t *testing.T) {
+	in := bytes.NewBufferString(`project_path = "site"
+css_dir = "css"
+css_path = "/var/www/css"
+`)
+	cfg, err := ReadCompassConfig(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := "/var/www/css"; cfg.BuildDir != e {
+		t.Errorf("got: %s wanted: %s", cfg.BuildDir, e)
+	}
+
+	cfg, err = ReadCompassConfig(bytes.NewBufferString(`project_path = "site"
+css_dir = "css"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := filepath.Join("site", "css"); cfg.BuildDir != e {
+		t.Errorf("got: %s wanted: %s", cfg.BuildDir, e)
+	}
+}
+
+func TestLoadConfig_compass(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(wd, "examples", "gulp")
+	cfg, err := LoadConfig(filepath.Join(dir, "config.rb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := filepath.Join(dir, "build", "im"); cfg.Gen != e {
+		t.Errorf("got: %s wanted: %s", cfg.Gen, e)
+	}
+	if e := filepath.Join(dir, "im"); cfg.ImageDir != e {
+		t.Errorf("got: %s wanted: %s", cfg.ImageDir, e)
+	}
+	if len(cfg.Warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", cfg.Warnings)
+	}
+}
+
+func TestLoadConfig_compassConfigDir(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testloadconfig_compassdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	if err := os.MkdirAll(filepath.Join(tdir, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tdir, "config", "compass.rb")
+	err = ioutil.WriteFile(path, []byte("css_dir = 'css'\nsass_dir = 'sass'\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Directories are relative to the project, not the config directory
+	if e := filepath.Join(tdir, "css"); cfg.BuildDir != e {
+		t.Errorf("got: %s wanted: %s", cfg.BuildDir, e)
+	}
+	if e := []string{filepath.Join(tdir, "sass")}; !reflect.DeepEqual(cfg.Paths, e) {
+		t.Errorf("got: %v wanted: %v", cfg.Paths, e)
+	}
+	if cfg.Dir() != tdir {
+		t.Errorf("got: %s wanted: %s", cfg.Dir(), tdir)
+	}
+}
+
+func TestFindConfig_compass(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testfindconfig_compass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sub := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tdir, "config.rb")
+	if err := ioutil.WriteFile(path, []byte("css_dir = 'css'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// config.rb in a parent is not a Compass project of sub
+	if found := FindConfig(sub); found != "" {
+		t.Errorf("got: %s wanted none", found)
+	}
+	if found := FindConfig(tdir); found != path {
+		t.Errorf("got: %s wanted: %s", found, path)
+	}
+}
@@ -0,0 +1,129 @@
+package wellington
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressor writes a compressed sibling of a file, ie. main.css.gz
+// for servers like nginx with gzip_static
+type compressor struct {
+	ext    string
+	writer func(io.Writer) io.WriteCloser
+}
+
+// compressors are the formats available to BuildArgs.Compress
+var compressors = map[string]compressor{
+	"gzip": {".gz", func(w io.Writer) io.WriteCloser {
+		// the header is left empty so the output only depends on the
+		// input
+		gz, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return gz
+	}},
+	"brotli": {".br", func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestCompression)
+	}},
+}
+
+// checkCompress validates the names of formats
+func checkCompress(formats []string) error {
+	for _, format := range formats {
+		if _, ok := compressors[format]; ok {
+			continue
+		}
+		names := make([]string, 0, len(compressors))
+		for name := range compressors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown compression: %s, available: %v",
+			format, names)
+	}
+	return nil
+}
+
+// compressOutput writes the siblings of out, the CSS built from path,
+// the other files derived from it and its source map when present in
+// every format of Compress
+func (b *BuildArgs) compressOutput(path, out string, derived []string) error {
+	if len(b.Compress) == 0 || len(b.BuildDir) == 0 {
+		return nil
+	}
+	paths := append([]string{out}, derived...)
+	if smap := b.outPath(path) + ".map"; b.SourceMap {
+		if _, err := os.Stat(smap); err == nil {
+			paths = append(paths, smap)
+		}
+	}
+	for _, path := range paths {
+		if err := compressFile(path, b.Compress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compressFile writes a sibling of path for each format. Siblings are
+// only replaced when their content changes, so servers and caches
+// relying on modification times are not disturbed.
+func compressFile(path string, formats []string) error {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, format := range formats {
+		c, ok := compressors[format]
+		if !ok {
+			return checkCompress([]string{format})
+		}
+		var buf bytes.Buffer
+		w := c.writer(&buf)
+		if _, err := w.Write(bs); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		dst := path + c.ext
+		if old, err := ioutil.ReadFile(dst); err == nil &&
+			bytes.Equal(old, buf.Bytes()) {
+			continue
+		}
+		if err := writeFile(dst, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile replaces path with bs, readers never see a partial file
+func writeFile(path string, bs []byte) error {
+	dir, base := filepath.Split(path)
+	f, err := ioutil.TempFile(dir, "."+base+".")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(bs); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,145 @@
+package wellington
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestCompressFile(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testcompressfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	path := filepath.Join(tdir, "main.css")
+	contents := "div {\n  color: red; }\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressFile(path, []string{"gzip", "brotli"}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadAll(gz)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != contents {
+		t.Errorf("got: %q wanted: %q", bs, contents)
+	}
+
+	f, err = os.Open(path + ".br")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = ioutil.ReadAll(brotli.NewReader(f))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != contents {
+		t.Errorf("got: %q wanted: %q", bs, contents)
+	}
+
+	// Unchanged copies are not rewritten
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path+".gz", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressFile(path, []string{"gzip"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("unchanged copy was rewritten")
+	}
+
+	err = compressFile(path, []string{"zip"})
+	if e := "unknown compression: zip, available: [brotli gzip]"; err == nil || err.Error() != e {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+}
+
+func TestBuild_compress(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(sdir, "a.scss"),
+		[]byte("div {\n  color: red; }\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bdir := filepath.Join(tdir, "build")
+	args := &BuildArgs{
+		BuildDir:  bdir,
+		SourceMap: true,
+		Compress:  []string{"gzip"},
+		Manifest:  filepath.Join(bdir, "manifest.json"),
+		Prune:     true,
+	}
+	args.WithPaths([]string{sdir})
+	b := NewBuild(args, NewPartialMap())
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.css.gz", "a.css.map.gz"} {
+		if _, err := os.Stat(filepath.Join(bdir, name)); err != nil {
+			t.Errorf("missing %s: %s", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(bdir, "a.css.br")); !os.IsNotExist(err) {
+		t.Errorf("unexpected brotli copy: %v", err)
+	}
+	if p := b.Pruned(); len(p) > 0 {
+		t.Errorf("got: %v wanted none", p)
+	}
+
+	// Copies of a format no longer written are pruned
+	args.Compress = []string{"brotli"}
+	b = NewBuild(args, NewPartialMap())
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	stale := []string{
+		filepath.Join(bdir, "a.css.gz"),
+		filepath.Join(bdir, "a.css.map.gz"),
+	}
+	if p := b.Pruned(); !reflect.DeepEqual(p, stale) {
+		t.Errorf("got: %v wanted: %v", p, stale)
+	}
+
+	args.Compress = []string{"bzip2"}
+	err = NewBuild(args, NewPartialMap()).Run()
+	if err == nil {
+		t.Error("unknown compression not reported")
+	}
+}
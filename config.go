@@ -0,0 +1,362 @@
+package wellington
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	libsass "github.com/wellington/go-libsass"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigNames are the project configuration files searched for by
+// FindConfig, in order of preference.
+var ConfigNames = []string{"wt.yaml", "wt.yml", "wt.json"}
+
+// CompassConfigNames are the Compass configuration files FindConfig
+// falls back to. Ruby files by these names are common outside of
+// Compass projects, so they are only looked for in the starting
+// directory.
+var CompassConfigNames = []string{"config.rb", filepath.Join("config", "compass.rb")}
+
+// ErrConfigFormat is returned when a configuration file does not end
+// in a known extension.
+var ErrConfigFormat = errors.New("config must be a .yaml, .yml, .json or Compass .rb file")
+
+// Config is the project configuration file. Every field maps onto a
+// field of BuildArgs, keys match the long name of the equivalent
+// command line flag. Relative paths are resolved against the directory
+// holding the configuration file.
+type Config struct {
+	// Paths are the files and directories compiled when none are
+	// passed on the command line
+	Paths     []string `json:"paths" yaml:"paths"`
+	Project   string   `json:"proj" yaml:"proj"`
+	BuildDir  string   `json:"build" yaml:"build"`
+	ImageDir  string   `json:"dir" yaml:"dir"`
+	Font      string   `json:"font" yaml:"font"`
+	Gen       string   `json:"gen" yaml:"gen"`
+	Includes  []string `json:"includes" yaml:"includes"`
+	Style     string   `json:"style" yaml:"style"`
+	Comments  bool     `json:"comment" yaml:"comment"`
+	CacheBust string   `json:"cachebust" yaml:"cachebust"`
+	SourceMap bool     `json:"source-map" yaml:"source-map"`
+	Manifest  string   `json:"manifest" yaml:"manifest"`
+	// Cache is the directory of the incremental build cache
+	Cache string `json:"cache" yaml:"cache"`
+	// Jobs is the number of files compiled at once
+	Jobs int `json:"jobs" yaml:"jobs"`
+	// Include and Exclude are glob patterns selecting the Sass files
+	// built from paths
+	Include []string `json:"include" yaml:"include"`
+	Exclude []string `json:"exclude" yaml:"exclude"`
+	// Compress lists the compressed copies of the CSS written ie. gzip
+	Compress []string `json:"compress" yaml:"compress"`
+	// Processors are the registered Processors run on the CSS, in order
+	Processors []string `json:"processors" yaml:"processors"`
+	// Browsers is the query of browsers autoprefixer supports
+	Browsers string `json:"browsers" yaml:"browsers"`
+	// MaxSelectors splits CSS with more selectors into parts
+	MaxSelectors int `json:"max-selectors" yaml:"max-selectors"`
+	// RTL writes a mirrored copy of each CSS file
+	RTL bool `json:"rtl" yaml:"rtl"`
+	// Overrides change the options of matching files, in order
+	Overrides []Override `json:"overrides" yaml:"overrides"`
+	// HTTPPath is only used by serve, see the httppath flag
+	HTTPPath string `json:"httppath" yaml:"httppath"`
+	// Variables are Sass variables declared before every stylesheet
+	Variables map[string]string `json:"variables" yaml:"variables"`
+	// Environments are named profiles that override settings, one is
+	// selected with UseEnvironment
+	Environments map[string]*Profile `json:"environments" yaml:"environments"`
+	// Targets are built together in place of Paths, see SelectTargets
+	Targets map[string]*Target `json:"targets" yaml:"targets"`
+
+	// Warnings are problems encountered interpreting the config that
+	// did not prevent it from loading
+	Warnings []string `json:"-" yaml:"-"`
+
+	// dir is the directory containing the configuration file
+	dir string
+}
+
+// Profile overrides Config settings for a named environment ie.
+// development or production. Empty fields leave the setting unchanged.
+type Profile struct {
+	Style     string `json:"style" yaml:"style"`
+	CacheBust string `json:"cachebust" yaml:"cachebust"`
+	SourceMap *bool  `json:"source-map" yaml:"source-map"`
+	Comments  *bool  `json:"comment" yaml:"comment"`
+	// Variables are merged with, and take precedence over, the
+	// variables of the config
+	Variables map[string]string `json:"variables" yaml:"variables"`
+}
+
+// FindConfig searches dir and each of its parents for a project
+// configuration file, Compass configuration files are only searched for
+// in dir. An empty string is returned if none is found.
+func FindConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	names := append(append([]string{}, ConfigNames...), CompassConfigNames...)
+	for {
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+		names = ConfigNames
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig reads the configuration file at path. The format is
+// determined by the file extension.
+func LoadConfig(path string) (*Config, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, err := ReadConfig(f, filepath.Ext(abs))
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %s", path, err)
+	}
+	cfg.dir = filepath.Dir(abs)
+	// Compass resolves paths against the project, the parent of a
+	// config/compass.rb
+	if filepath.Ext(abs) == ".rb" && filepath.Base(cfg.dir) == "config" {
+		cfg.dir = filepath.Dir(cfg.dir)
+	}
+	cfg.resolve()
+	return cfg, nil
+}
+
+// ReadConfig decodes a configuration of the format described by ext
+// ie. .json or .yaml from r. Unknown keys are reported as errors.
+// Compass .rb files are read with ReadCompassConfig. Relative paths
+// are left untouched.
+func ReadConfig(r io.Reader, ext string) (*Config, error) {
+	cfg := &Config{}
+	switch ext {
+	case ".yaml", ".yml":
+		bs, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(bs, cfg); err != nil {
+			return nil, err
+		}
+	case ".json":
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil && err != io.EOF {
+			return nil, err
+		}
+	case ".rb":
+		var err error
+		cfg, err = ReadCompassConfig(r)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrConfigFormat
+	}
+
+	if err := validStyle(cfg.Style); err != nil {
+		return nil, err
+	}
+	for i, o := range cfg.Overrides {
+		if len(o.Match) == 0 {
+			return nil, fmt.Errorf("override %d: match is required", i+1)
+		}
+		if err := validStyle(o.Style); err != nil {
+			return nil, fmt.Errorf("override %s: %s", o.Match, err)
+		}
+	}
+	for name, p := range cfg.Environments {
+		if p == nil {
+			return nil, fmt.Errorf("environment %s is empty", name)
+		}
+		if err := validStyle(p.Style); err != nil {
+			return nil, fmt.Errorf("environment %s: %s", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+func validStyle(style string) error {
+	if len(style) == 0 {
+		return nil
+	}
+	if _, ok := libsass.Style[style]; !ok {
+		return fmt.Errorf("invalid style: %s", style)
+	}
+	return nil
+}
+
+// UseEnvironment applies the settings of the named environment to the
+// config.
+func (c *Config) UseEnvironment(name string) error {
+	p, ok := c.Environments[name]
+	if !ok {
+		names := make([]string, 0, len(c.Environments))
+		for n := range c.Environments {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("environment %s not found, available: %v",
+			name, names)
+	}
+
+	if len(p.Style) > 0 {
+		c.Style = p.Style
+	}
+	if len(p.CacheBust) > 0 {
+		c.CacheBust = p.CacheBust
+	}
+	if p.SourceMap != nil {
+		c.SourceMap = *p.SourceMap
+	}
+	if p.Comments != nil {
+		c.Comments = *p.Comments
+	}
+	if len(p.Variables) > 0 {
+		vars := make(map[string]string, len(c.Variables)+len(p.Variables))
+		for k, v := range c.Variables {
+			vars[strings.TrimPrefix(k, "$")] = v
+		}
+		for k, v := range p.Variables {
+			vars[strings.TrimPrefix(k, "$")] = v
+		}
+		c.Variables = vars
+	}
+	return nil
+}
+
+// SassHeader returns Sass declaring each of the config variables. The
+// result is meant to be registered with libsass.RegisterHeader.
+func (c *Config) SassHeader() string {
+	names := make([]string, 0, len(c.Variables))
+	for name := range c.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "$%s: %s;\n",
+			strings.TrimPrefix(name, "$"), c.Variables[name])
+	}
+	return buf.String()
+}
+
+// Dir returns the directory the relative paths of the configuration
+// are resolved against, the one containing the file or the project of
+// a Compass config/compass.rb
+func (c *Config) Dir() string {
+	return c.dir
+}
+
+// resolve makes all paths in the config absolute
+func (c *Config) resolve() {
+	abs := func(path string) string {
+		if len(path) == 0 || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(c.dir, path)
+	}
+	for i := range c.Paths {
+		c.Paths[i] = abs(c.Paths[i])
+	}
+	for i := range c.Includes {
+		c.Includes[i] = abs(c.Includes[i])
+	}
+	c.Project = abs(c.Project)
+	c.BuildDir = abs(c.BuildDir)
+	c.ImageDir = abs(c.ImageDir)
+	c.Font = abs(c.Font)
+	c.Gen = abs(c.Gen)
+	c.Manifest = abs(c.Manifest)
+	c.Cache = abs(c.Cache)
+	for i := range c.Overrides {
+		c.Overrides[i].ImageDir = abs(c.Overrides[i].ImageDir)
+	}
+
+	for _, t := range c.Targets {
+		if t == nil {
+			continue
+		}
+		for i := range t.Paths {
+			t.Paths[i] = abs(t.Paths[i])
+		}
+		for i := range t.Includes {
+			t.Includes[i] = abs(t.Includes[i])
+		}
+		t.Project = abs(t.Project)
+		t.BuildDir = abs(t.BuildDir)
+		t.ImageDir = abs(t.ImageDir)
+		t.Font = abs(t.Font)
+		t.Gen = abs(t.Gen)
+		t.Manifest = abs(t.Manifest)
+	}
+}
+
+// BuildArgs creates BuildArgs from the configuration
+func (c *Config) BuildArgs() *BuildArgs {
+	style, ok := libsass.Style[c.Style]
+	if !ok {
+		style = libsass.NESTED_STYLE
+	}
+	wd := c.dir
+	if len(wd) == 0 {
+		wd, _ = os.Getwd()
+	}
+	gba := &BuildArgs{
+		WorkDir:    wd,
+		ImageDir:   c.ImageDir,
+		BuildDir:   c.BuildDir,
+		Includes:   append(append([]string{}, c.Includes...), c.Paths...),
+		Font:       c.Font,
+		Style:      style,
+		Gen:        c.Gen,
+		Project:    c.Project,
+		Comments:   c.Comments,
+		CacheBust:  c.CacheBust,
+		SourceMap:  c.SourceMap,
+		Manifest:   c.Manifest,
+		Cache:      c.Cache,
+		Header:     c.SassHeader(),
+		Jobs:       c.Jobs,
+		Include:    c.Include,
+		Exclude:    c.Exclude,
+		Compress:   c.Compress,
+		Processors: c.Processors,
+		Browsers:   c.Browsers,
+		Overrides:  c.Overrides,
+
+		MaxSelectors: c.MaxSelectors,
+		RTL:          c.RTL,
+	}
+	gba.WithPaths(append([]string{}, c.Paths...))
+	return gba
+}
@@ -0,0 +1,217 @@
+package wellington
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	libsass "github.com/wellington/go-libsass"
+)
+
+func TestReadConfig_yaml(t *testing.T) {
+	in := bytes.NewBufferString(`
+paths:
+  - sass
+build: build/css
+dir: img
+gen: build/img
+includes:
+  - vendor
+style: compressed
+comment: true
+cachebust: sum
+source-map: true
+browsers: last 2 versions, ie 11
+`)
+	cfg, err := ReadConfig(in, ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := "build/css"; cfg.BuildDir != e {
+		t.Errorf("got: %s wanted: %s", cfg.BuildDir, e)
+	}
+	if e := "compressed"; cfg.Style != e {
+		t.Errorf("got: %s wanted: %s", cfg.Style, e)
+	}
+	if !cfg.Comments || !cfg.SourceMap {
+		t.Errorf("comment and source-map were not set: % #v", cfg)
+	}
+	if e := 1; len(cfg.Includes) != e {
+		t.Errorf("got: %d wanted: %d", len(cfg.Includes), e)
+	}
+	if e := "last 2 versions, ie 11"; cfg.BuildArgs().Browsers != e {
+		t.Errorf("got: %s wanted: %s", cfg.BuildArgs().Browsers, e)
+	}
+}
+
+func TestReadConfig_json(t *testing.T) {
+	in := bytes.NewBufferString(`{"build": "build", "font": "fonts", "style": "expanded"}`)
+	cfg, err := ReadConfig(in, ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := "fonts"; cfg.Font != e {
+		t.Errorf("got: %s wanted: %s", cfg.Font, e)
+	}
+}
+
+func TestReadConfig_errors(t *testing.T) {
+	_, err := ReadConfig(bytes.NewBufferString(`bulid: build`), ".yaml")
+	if err == nil || !strings.Contains(err.Error(), "bulid") {
+		t.Errorf("unknown key not reported: %v", err)
+	}
+
+	_, err = ReadConfig(bytes.NewBufferString(`{"bulid": "build"}`), ".json")
+	if err == nil || !strings.Contains(err.Error(), "bulid") {
+		t.Errorf("unknown key not reported: %v", err)
+	}
+
+	_, err = ReadConfig(bytes.NewBufferString(`style: fancy`), ".yml")
+	if e := "invalid style: fancy"; err == nil || err.Error() != e {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+
+	_, err = ReadConfig(bytes.NewBufferString(``), ".toml")
+	if err != ErrConfigFormat {
+		t.Errorf("got: %v wanted: %s", err, ErrConfigFormat)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testloadconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sub := filepath.Join(tdir, "sass", "nested")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if path := FindConfig(sub); path != "" {
+		t.Fatalf("unexpected config found: %s", path)
+	}
+
+	path := filepath.Join(tdir, "wt.yaml")
+	err = ioutil.WriteFile(path, []byte("paths: [sass]\nbuild: build\nstyle: compact\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found := FindConfig(sub); found != path {
+		t.Fatalf("got: %s wanted: %s", found, path)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := filepath.Join(tdir, "build"); cfg.BuildDir != e {
+		t.Errorf("got: %s wanted: %s", cfg.BuildDir, e)
+	}
+
+	gba := cfg.BuildArgs()
+	if e := filepath.Join(tdir, "sass"); len(gba.Paths()) != 1 || gba.Paths()[0] != e {
+		t.Errorf("got: %v wanted: %s", gba.Paths(), e)
+	}
+	if gba.Style != libsass.COMPACT_STYLE {
+		t.Errorf("got: %d wanted: %d", gba.Style, libsass.COMPACT_STYLE)
+	}
+}
+
+func TestConfig_UseEnvironment(t *testing.T) {
+	in := bytes.NewBufferString(`
+style: expanded
+cachebust: ts
+comment: true
+variables:
+  asset-host: "'/static'"
+  debug: "true"
+environments:
+  production:
+    style: compressed
+    cachebust: sum
+    comment: false
+    source-map: true
+    variables:
+      $debug: "false"
+  development:
+    source-map: true
+`)
+	cfg, err := ReadConfig(in, ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.UseEnvironment("staging"); err == nil {
+		t.Error("no error for missing environment")
+	}
+
+	if err := cfg.UseEnvironment("production"); err != nil {
+		t.Fatal(err)
+	}
+	if e := "compressed"; cfg.Style != e {
+		t.Errorf("got: %s wanted: %s", cfg.Style, e)
+	}
+	if e := "sum"; cfg.CacheBust != e {
+		t.Errorf("got: %s wanted: %s", cfg.CacheBust, e)
+	}
+	if cfg.Comments || !cfg.SourceMap {
+		t.Errorf("profile booleans not applied: % #v", cfg)
+	}
+
+	e := "$asset-host: '/static';\n$debug: false;\n"
+	if hdr := cfg.SassHeader(); hdr != e {
+		t.Errorf("got:\n%s\nwanted:\n%s", hdr, e)
+	}
+}
+
+func TestReadConfig_environmentStyle(t *testing.T) {
+	in := bytes.NewBufferString(`
+environments:
+  production:
+    style: smallest
+`)
+	_, err := ReadConfig(in, ".yaml")
+	if e := "environment production: invalid style: smallest"; err == nil || err.Error() != e {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+}
+
+func TestReadConfig_overrides(t *testing.T) {
+	in := bytes.NewBufferString(`
+overrides:
+  - match: "pages/*.scss"
+    style: compressed
+    source-map: false
+`)
+	cfg, err := ReadConfig(in, ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Overrides) != 1 {
+		t.Fatalf("got: %d wanted: 1", len(cfg.Overrides))
+	}
+	o := cfg.Overrides[0]
+	if o.Match != "pages/*.scss" || o.Style != "compressed" {
+		t.Errorf("got: % #v", o)
+	}
+	if o.SourceMap == nil || *o.SourceMap || o.Comments != nil {
+		t.Errorf("got: % #v", o)
+	}
+
+	_, err = ReadConfig(bytes.NewBufferString(`
+overrides:
+  - style: compressed
+`), ".yaml")
+	if e := "override 1: match is required"; err == nil || err.Error() != e {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+}
@@ -0,0 +1,231 @@
+package wellington
+
+import (
+	"sort"
+	"sync"
+)
+
+// DepGraph is a thread safe graph of the imports between Sass files,
+// named by their absolute paths. Edges point from a file to the files
+// it imports directly.
+type DepGraph struct {
+	mu      sync.RWMutex
+	imports map[string][]string
+	users   map[string][]string
+}
+
+// NewDepGraph returns an empty DepGraph
+func NewDepGraph() *DepGraph {
+	return &DepGraph{
+		imports: make(map[string][]string),
+		users:   make(map[string][]string),
+	}
+}
+
+// SetImports replaces the files imported directly by file, the edges
+// to files it no longer imports are removed
+func (g *DepGraph) SetImports(file string, imports []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.unlink(file)
+	var sorted []string
+	for _, imp := range imports {
+		sorted = appendUnique(sorted, imp)
+	}
+	sort.Strings(sorted)
+	if len(sorted) > 0 {
+		g.imports[file] = sorted
+	}
+	for _, imp := range sorted {
+		g.users[imp] = insertSorted(g.users[imp], file)
+	}
+}
+
+// Remove drops the imports of file. Files importing it keep their edges
+// until their imports are set again.
+func (g *DepGraph) Remove(file string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.unlink(file)
+}
+
+// unlink removes the edges out of file, g.mu must be held
+func (g *DepGraph) unlink(file string) {
+	for _, imp := range g.imports[file] {
+		users := removeString(g.users[imp], file)
+		if len(users) == 0 {
+			delete(g.users, imp)
+		} else {
+			g.users[imp] = users
+		}
+	}
+	delete(g.imports, file)
+}
+
+// Files returns every file importing or imported, sorted
+func (g *DepGraph) Files() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var files []string
+	for f := range g.imports {
+		files = append(files, f)
+	}
+	for f := range g.users {
+		if _, ok := g.imports[f]; !ok {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Imports returns the files imported directly by file, sorted
+func (g *DepGraph) Imports(file string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]string(nil), g.imports[file]...)
+}
+
+// Importers returns the files importing file directly, sorted
+func (g *DepGraph) Importers(file string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]string(nil), g.users[file]...)
+}
+
+// Dependencies returns every file file imports, directly or through
+// other imports, sorted
+func (g *DepGraph) Dependencies(file string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return walkEdges(g.imports, file)
+}
+
+// Dependents returns every file importing file, directly or through
+// other imports, sorted
+func (g *DepGraph) Dependents(file string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return walkEdges(g.users, file)
+}
+
+// walkEdges returns the files reachable from file, file is only
+// included when it is part of a cycle
+func walkEdges(edges map[string][]string, file string) []string {
+	seen := make(map[string]bool)
+	stack := append([]string(nil), edges[file]...)
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		stack = append(stack, edges[f]...)
+	}
+	files := make([]string, 0, len(seen))
+	for f := range seen {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Cycles returns the groups of files importing each other, directly or
+// through other imports. Each group and the list of them are sorted.
+func (g *DepGraph) Cycles() [][]string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	// Tarjan's strongly connected components
+	var (
+		index   = make(map[string]int)
+		low     = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  [][]string
+	)
+	var visit func(f string)
+	visit = func(f string) {
+		index[f] = len(index)
+		low[f] = index[f]
+		stack = append(stack, f)
+		onStack[f] = true
+		for _, imp := range g.imports[f] {
+			if _, ok := index[imp]; !ok {
+				visit(imp)
+				if low[imp] < low[f] {
+					low[f] = low[imp]
+				}
+			} else if onStack[imp] && index[imp] < low[f] {
+				low[f] = index[imp]
+			}
+		}
+		if low[f] != index[f] {
+			return
+		}
+		var group []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			group = append(group, top)
+			if top == f {
+				break
+			}
+		}
+		// a file importing itself is a cycle of one
+		if len(group) > 1 || g.importsDirectly(f, f) {
+			sort.Strings(group)
+			cycles = append(cycles, group)
+		}
+	}
+
+	files := make([]string, 0, len(g.imports))
+	for f := range g.imports {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if _, ok := index[f]; !ok {
+			visit(f)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
+
+// importsDirectly reports whether from imports to, g.mu must be held
+func (g *DepGraph) importsDirectly(from, to string) bool {
+	for _, imp := range g.imports[from] {
+		if imp == to {
+			return true
+		}
+	}
+	return false
+}
+
+// insertSorted adds s to the sorted slice, once
+func insertSorted(slice []string, s string) []string {
+	i := sort.SearchStrings(slice, s)
+	if i < len(slice) && slice[i] == s {
+		return slice
+	}
+	slice = append(slice, "")
+	copy(slice[i+1:], slice[i:])
+	slice[i] = s
+	return slice
+}
+
+// removeString returns slice without s
+func removeString(slice []string, s string) []string {
+	var kept []string
+	for _, e := range slice {
+		if e != s {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,135 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDepGraph(t *testing.T) {
+	g := NewDepGraph()
+	g.SetImports("main", []string{"_b", "_a"})
+	g.SetImports("_a", []string{"_c"})
+	g.SetImports("other", []string{"_c"})
+
+	if got, e := g.Imports("main"), []string{"_a", "_b"}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	if got, e := g.Importers("_c"), []string{"_a", "other"}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	if got, e := g.Dependencies("main"), []string{"_a", "_b", "_c"}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	if got, e := g.Dependents("_c"), []string{"_a", "main", "other"}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+
+	// Edges to files no longer imported are removed
+	g.SetImports("_a", nil)
+	if got, e := g.Dependents("_c"), []string{"other"}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	g.Remove("other")
+	if got := g.Importers("_c"); len(got) > 0 {
+		t.Errorf("got: %v wanted none", got)
+	}
+
+	if got, e := g.Files(), []string{"_a", "_b", "main"}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	if got := g.Cycles(); len(got) > 0 {
+		t.Errorf("got: %v wanted no cycles", got)
+	}
+	g.SetImports("_b", []string{"_d"})
+	g.SetImports("_d", []string{"main"})
+	g.SetImports("_e", []string{"_e"})
+	e := [][]string{{"_b", "_d", "main"}, {"_e"}}
+	if got := g.Cycles(); !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	// Cycles do not trap the queries
+	if got, e := g.Dependents("_b"), []string{"_b", "_d", "main"}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+}
+
+func TestBuild_graph(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	idir := filepath.Join(tdir, "includes")
+	for _, dir := range []string{sdir, filepath.Join(sdir, "sub"), idir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	main := filepath.Join(sdir, "main.scss")
+	p := filepath.Join(sdir, "_p.scss")
+	q := filepath.Join(sdir, "sub", "_q.scss")
+	inc := filepath.Join(idir, "_inc.scss")
+	files := map[string]string{
+		main: `@import "p"; @import "inc";`,
+		p:    `@import "sub/q"; a { color: red; }`,
+		q:    `b { color: red; }`,
+		inc:  `c { color: red; }`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bArgs := &BuildArgs{
+		BuildDir: filepath.Join(tdir, "build"),
+		Includes: []string{idir},
+	}
+	bArgs.WithPaths([]string{sdir})
+	pmap := NewPartialMap()
+	if err := NewBuild(bArgs, pmap).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, e := pmap.Graph.Dependencies(main), []string{inc, p, q}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	if got, e := pmap.Graph.Importers(q), []string{main}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	if got, _ := pmap.topLevel(q); !reflect.DeepEqual(got, []string{main}) {
+		t.Errorf("got: %v wanted: %s", got, main)
+	}
+
+	// Imports removed from a partial are removed from the graph
+	if err := ioutil.WriteFile(p, []byte(`a { color: red; }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadAndBuild(main, bArgs, pmap); err != nil {
+		t.Fatal(err)
+	}
+	if got := pmap.Graph.Importers(q); len(got) > 0 {
+		t.Errorf("got: %v wanted no importers", got)
+	}
+	if got, ok := pmap.topLevel(q); ok {
+		t.Errorf("got: %v wanted no top level files", got)
+	}
+	if got, e := pmap.importsOf(main), []string{inc, p}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+
+	// Files failing to build keep the imports of their last build
+	if err := ioutil.WriteFile(p, []byte(`@import "sub/q"; a {`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadAndBuild(main, bArgs, pmap); err == nil {
+		t.Fatal("wanted a build error")
+	}
+	if got, e := pmap.Graph.Dependencies(main), []string{inc, p}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+}
@@ -0,0 +1,95 @@
+package wellington
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FileError is a failure to build a single Sass file
+type FileError struct {
+	// File is the Sass file being built
+	File string `json:"file"`
+	// Source is the file the error occurred in, either File or one of
+	// its imports
+	Source string `json:"source"`
+	// Line is 0 when the compiler does not report one. libsass
+	// reports no column.
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+
+	err error
+}
+
+// newFileError records err as a failure building file. Compiler errors
+// in the form "Error > path:line\nmessage" are split into their parts.
+func newFileError(file string, err error) *FileError {
+	if fe, ok := err.(*FileError); ok {
+		return fe
+	}
+	fe := &FileError{
+		File:    file,
+		Source:  file,
+		Message: err.Error(),
+		err:     err,
+	}
+
+	const prefix = "Error > "
+	msg := err.Error()
+	if !strings.HasPrefix(msg, prefix) {
+		return fe
+	}
+	head, rest := msg[len(prefix):], ""
+	if i := strings.Index(head, "\n"); i >= 0 {
+		head, rest = head[:i], head[i+1:]
+	}
+	// path:line, the path may contain a colon ie. c:/src/main.scss
+	i := strings.LastIndex(head, ":")
+	if i < 0 {
+		return fe
+	}
+	line, err := strconv.Atoi(head[i+1:])
+	if err != nil {
+		return fe
+	}
+	fe.Source = head[:i]
+	fe.Line = line
+	fe.Message = strings.TrimSpace(rest)
+	return fe
+}
+
+// Error returns the original error, a FileError decoded from a report
+// has only its parts
+func (e *FileError) Error() string {
+	if e.err == nil {
+		return e.String()
+	}
+	return e.err.Error()
+}
+
+// String formats the error as source:line: message
+func (e *FileError) String() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.Source, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Source, e.Line, e.Message)
+}
+
+// Errors are all of the files that failed in a Build run with
+// KeepGoing, in build order
+type Errors []*FileError
+
+// Error summarizes every failure
+func (e Errors) Error() string {
+	var buf bytes.Buffer
+	for _, fe := range e {
+		fmt.Fprintln(&buf, fe.String())
+	}
+	files := "files"
+	if len(e) == 1 {
+		files = "file"
+	}
+	fmt.Fprintf(&buf, "%d %s failed to build", len(e), files)
+	return buf.String()
+}
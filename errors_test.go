@@ -0,0 +1,106 @@
+package wellington
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewFileError(t *testing.T) {
+	err := errors.New("Error > /src/main.scss:3\nInvalid CSS after \"div {\"")
+	fe := newFileError("main.scss", err)
+	if e := "/src/main.scss"; fe.Source != e {
+		t.Errorf("got: %s wanted: %s", fe.Source, e)
+	}
+	if e := 3; fe.Line != e {
+		t.Errorf("got: %d wanted: %d", fe.Line, e)
+	}
+	if e := "/src/main.scss:3: Invalid CSS after \"div {\""; fe.String() != e {
+		t.Errorf("got: %s wanted: %s", fe.String(), e)
+	}
+	if fe.Error() != err.Error() {
+		t.Errorf("got: %s wanted: %s", fe.Error(), err)
+	}
+
+	fe = newFileError("c:/src/main.scss", errors.New("Error > c:/src/_a.scss:4\nbad"))
+	if fe.Source != "c:/src/_a.scss" || fe.Line != 4 {
+		t.Errorf("got: %s %d", fe.Source, fe.Line)
+	}
+
+	fe = newFileError("main.scss", errors.New("permission denied"))
+	if e := "main.scss: permission denied"; fe.String() != e {
+		t.Errorf("got: %s wanted: %s", fe.String(), e)
+	}
+
+	// Errors decoded from a report have no original error
+	var decoded FileError
+	if err := json.Unmarshal([]byte(`{"source":"_a.scss","line":2,"message":"bad"}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if e := "_a.scss:2: bad"; decoded.Error() != e {
+		t.Errorf("got: %s wanted: %s", decoded.Error(), e)
+	}
+}
+
+func TestBuild_keepGoing(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_keepgoing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.scss": "div {",
+		"b.scss": "div { color: red; }",
+		"c.scss": "p { color: red; }\np {",
+	}
+	for name, contents := range files {
+		err := ioutil.WriteFile(filepath.Join(sdir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bdir := filepath.Join(tdir, "build")
+	args := &BuildArgs{BuildDir: bdir, KeepGoing: true}
+	args.WithPaths([]string{sdir})
+	err = NewBuild(args, NewPartialMap()).Run()
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("got: %T %v wanted: Errors", err, err)
+	}
+	if e := 2; len(errs) != e {
+		t.Fatalf("got: %d wanted: %d", len(errs), e)
+	}
+	if e := filepath.Join(sdir, "c.scss"); errs[1].File != e {
+		t.Errorf("got: %s wanted: %s", errs[1].File, e)
+	}
+	if e := 2; errs[1].Line != e {
+		t.Errorf("got: %d wanted: %d", errs[1].Line, e)
+	}
+	if !strings.HasSuffix(errs.Error(), "2 files failed to build") {
+		t.Errorf("invalid summary: %s", errs)
+	}
+	if _, err := os.Stat(filepath.Join(bdir, "b.css")); err != nil {
+		t.Error(err)
+	}
+
+	args = &BuildArgs{BuildDir: bdir, Jobs: 1}
+	args.WithPaths([]string{sdir})
+	err = NewBuild(args, NewPartialMap()).Run()
+	fe, ok := err.(*FileError)
+	if !ok {
+		t.Fatalf("got: %T %v wanted: *FileError", err, err)
+	}
+	if e := filepath.Join(sdir, "a.scss"); fe.File != e {
+		t.Errorf("got: %s wanted: %s", fe.File, e)
+	}
+}
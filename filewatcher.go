@@ -5,18 +5,34 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/wellington/wellington/payload"
 )
 
 // MaxTopLevel sets the default size of the slice holding the top level
 // files for a sass partial in SafePartialMap.M
 const MaxTopLevel int = 20
 
+// DefaultDebounce is the time wt watch waits for more changes before
+// rebuilding, editors often write a file more than once when saving
+const DefaultDebounce = 100 * time.Millisecond
+
 // WatchOptions containers the necessary parameters to run the file watcher
 type WatchOptions struct {
 	PartialMap *SafePartialMap
 	Paths      []string
 	BArgs      *BuildArgs
+	// LiveReload is notified of the CSS rebuilt, if set
+	LiveReload *LiveReload
+	// Debounce is the time to wait for more changes before rebuilding.
+	// The top level files affected by all of the changes are rebuilt
+	// once, changes are rebuilt right away when 0.
+	Debounce time.Duration
 }
 
 // NewWatchOptions returns a new WatchOptions
@@ -56,15 +72,85 @@ func NewWatcher(opts *WatchOptions) (*Watcher, error) {
 type SafePartialMap struct {
 	sync.RWMutex
 	M map[string][]string
+	// Assets maps the images, fonts and sprite globs used by top level
+	// files to those files, see AddAsset
+	Assets map[string][]string
+	// Graph holds the files libsass read to compile each top level
+	// file, see setImports
+	Graph *DepGraph
 }
 
 // NewPartialMap creates a initialized SafeParitalMap with with capacity 100
 func NewPartialMap() *SafePartialMap {
 	spm := &SafePartialMap{
-		M: make(map[string][]string, 100)}
+		M:      make(map[string][]string, 100),
+		Assets: make(map[string][]string),
+		Graph:  NewDepGraph()}
 	return spm
 }
 
+// graph returns Graph, creating it if needed
+func (p *SafePartialMap) graph() *DepGraph {
+	p.Lock()
+	defer p.Unlock()
+	if p.Graph == nil {
+		p.Graph = NewDepGraph()
+	}
+	return p.Graph
+}
+
+// setImports replaces the files mainfile was found to import, the
+// files libsass reported for its compile. mainfile is removed from the
+// entries of files it no longer imports, and its edges in Graph are
+// replaced.
+func (p *SafePartialMap) setImports(mainfile string, imports []string) {
+	abs := absPath(mainfile)
+	keep := map[string]bool{abs: true}
+	var edges []string
+	for _, imp := range imports {
+		keep[imp] = true
+		// libsass lists the file being compiled as an import
+		if imp != abs {
+			edges = append(edges, imp)
+		}
+	}
+	p.graph().SetImports(abs, edges)
+
+	p.Lock()
+	defer p.Unlock()
+	for key, mains := range p.M {
+		if keep[key] {
+			continue
+		}
+		kept := removeString(mains, mainfile)
+		if len(kept) == 0 {
+			delete(p.M, key)
+		} else if len(kept) < len(mains) {
+			p.M[key] = kept
+		}
+	}
+	for _, imp := range imports {
+		p.M[imp] = appendUnique(p.M[imp], mainfile)
+	}
+}
+
+// topLevel returns the top level files built with the file at path, the
+// ones found to import it directly or through other files
+func (p *SafePartialMap) topLevel(path string) ([]string, bool) {
+	mains, ok := p.Get(path)
+	mains = append([]string(nil), mains...)
+	for _, dep := range p.graph().Dependents(path) {
+		others, _ := p.Get(dep)
+		for _, m := range others {
+			if absPath(m) == dep {
+				mains = appendUnique(mains, m)
+				ok = true
+			}
+		}
+	}
+	return mains, ok
+}
+
 // Add places a path in the partial map
 func (p *SafePartialMap) Add(key string, paths []string) {
 	p.Lock()
@@ -87,6 +173,121 @@ func (p *SafePartialMap) AddRelation(mainfile string, subfile string) {
 	p.Add(subfile, appendUnique(existing, mainfile))
 }
 
+// AddAsset links an image, font or sprite glob, an absolute path, with
+// the top level file using it
+func (p *SafePartialMap) AddAsset(mainfile string, asset string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.Assets == nil {
+		p.Assets = make(map[string][]string)
+	}
+	p.Assets[asset] = appendUnique(p.Assets[asset], mainfile)
+}
+
+// setAssets replaces the assets used by mainfile
+func (p *SafePartialMap) setAssets(mainfile string, assets []string) {
+	p.Lock()
+	for asset, mains := range p.Assets {
+		if kept := removeString(mains, mainfile); len(kept) == 0 {
+			delete(p.Assets, asset)
+		} else if len(kept) < len(mains) {
+			p.Assets[asset] = kept
+		}
+	}
+	p.Unlock()
+	for _, asset := range assets {
+		p.AddAsset(mainfile, asset)
+	}
+}
+
+// assetsOf returns the assets mainfile was found to use, sorted
+func (p *SafePartialMap) assetsOf(mainfile string) []string {
+	p.RLock()
+	defer p.RUnlock()
+	var assets []string
+	for asset, mains := range p.Assets {
+		for _, m := range mains {
+			if m == mainfile {
+				assets = append(assets, asset)
+				break
+			}
+		}
+	}
+	sort.Strings(assets)
+	return assets
+}
+
+// assetUsers returns the top level files using the asset at path, or a
+// sprite glob matching it, sorted
+func (p *SafePartialMap) assetUsers(path string) []string {
+	p.RLock()
+	defer p.RUnlock()
+	var users []string
+	for asset, mains := range p.Assets {
+		if ok, _ := filepath.Match(asset, path); !ok && asset != path {
+			continue
+		}
+		for _, m := range mains {
+			users = appendUnique(users, m)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// importsOf returns the files mainfile was found to import, sorted
+func (p *SafePartialMap) importsOf(mainfile string) []string {
+	abs, _ := filepath.Abs(mainfile)
+	p.RLock()
+	defer p.RUnlock()
+	var imports []string
+	for partial, mains := range p.M {
+		// libsass lists the file being compiled as an import
+		if partial == mainfile || partial == abs {
+			continue
+		}
+		for _, m := range mains {
+			if m == mainfile {
+				imports = append(imports, partial)
+				break
+			}
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// removeMains drops the top level files at path, or in the directory
+// path, from the partial map and returns them
+func (p *SafePartialMap) removeMains(path string) []string {
+	gone := func(main string) bool {
+		abs := absPath(main)
+		return abs == path || strings.HasPrefix(abs, path+string(filepath.Separator))
+	}
+	p.Lock()
+	defer p.Unlock()
+	var removed []string
+	for _, m := range []map[string][]string{p.M, p.Assets} {
+		for key, mains := range m {
+			var kept []string
+			for _, main := range mains {
+				if gone(main) {
+					removed = appendUnique(removed, main)
+				} else {
+					kept = append(kept, main)
+				}
+			}
+			if len(kept) == 0 {
+				delete(m, key)
+			} else if len(kept) < len(mains) {
+				m[key] = kept
+			}
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
 var watcherChanMu sync.RWMutex
 var watcherChan chan string
 
@@ -121,14 +322,205 @@ func (w *Watcher) watchFiles() error {
 		if !os.IsNotExist(err) && filepath.IsAbs(dir) {
 			err = w.watch(dir)
 			if err != nil {
+				w.opts.PartialMap.RUnlock()
 				return err
 			}
 		}
 	}
 	w.opts.PartialMap.RUnlock()
+	// and of the imports of files that failed to build
+	for _, f := range w.opts.PartialMap.graph().Files() {
+		if err := w.watch(filepath.Dir(f)); err != nil {
+			return err
+		}
+	}
+	if err := w.watchAssets(); err != nil {
+		return err
+	}
+
+	// Watch the directories new Sass files may appear in
+	for _, path := range w.opts.Paths {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := w.watchDir(absPath(path), nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// watchAssets watches the directories of the images, fonts and sprite
+// globs in the partial map
+func (w *Watcher) watchAssets() error {
+	var dirs []string
+	w.opts.PartialMap.RLock()
+	for asset := range w.opts.PartialMap.Assets {
+		dir := filepath.Dir(asset)
+		// directories matched by a glob are not followed
+		if !strings.ContainsAny(dir, "*?[") {
+			dirs = appendUnique(dirs, dir)
+		}
+	}
+	w.opts.PartialMap.RUnlock()
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if err := w.watch(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashedCopy matches the content hashed copies of assets, see
+// CacheBust
+var hashedCopy = regexp.MustCompile(`\.[0-9a-f]{8}\.[^.]+$`)
+
+// assetUsers returns the top level files using the asset at path. Images
+// decoded by earlier builds are forgotten as they may have changed.
+// Sprites and content hashed copies written by builds are not assets.
+func (w *Watcher) assetUsers(path string) []string {
+	base := filepath.Base(path)
+	if hashedCopy.MatchString(base) {
+		return nil
+	}
+	gba := w.opts.BArgs
+	if gba != nil && spriteName.MatchString(base) &&
+		filepath.Dir(path) == absPath(gba.Gen) {
+		return nil
+	}
+	users := w.opts.PartialMap.assetUsers(path)
+	if len(users) > 0 && gba != nil && gba.Payload != nil {
+		payload.ForgetImages(gba.Payload)
+	}
+	return users
+}
+
+// watchDir watches the directory dir and the directories in it, except
+// hidden ones and those excluded from the build. Files found are passed
+// to found when it is set.
+func (w *Watcher) watchDir(dir string, found func(string) error) error {
+	var buildDir string
+	if w.opts.BArgs != nil && len(w.opts.BArgs.BuildDir) > 0 {
+		buildDir = absPath(w.opts.BArgs.BuildDir)
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// removed while walking
+			return nil
+		}
+		if !info.IsDir() {
+			if found == nil {
+				return nil
+			}
+			return found(path)
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") ||
+			path == buildDir {
+			return filepath.SkipDir
+		}
+		if _, ok := w.source(path, true); !ok {
+			return filepath.SkipDir
+		}
+		return w.watch(path)
+	})
+}
+
+// source returns path, an absolute path, as it is named in the watched
+// Paths. It reports whether path is in them and not skipped by the
+// Include and Exclude patterns of the build.
+func (w *Watcher) source(path string, dir bool) (string, bool) {
+	var include, exclude []string
+	if w.opts.BArgs != nil {
+		include, exclude = w.opts.BArgs.Include, w.opts.BArgs.Exclude
+	}
+	for _, root := range w.opts.Paths {
+		rel, err := filepath.Rel(absPath(root), path)
+		if err != nil || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		src := filepath.Join(root, rel)
+		if rel == "." {
+			return src, true
+		}
+		filter, err := newSourceFilter(root, include, exclude)
+		if err != nil {
+			log.Println(err)
+			return src, false
+		}
+		return src, !filter.skip(filepath.ToSlash(rel), dir)
+	}
+	return "", false
+}
+
+// created handles a file or directory added to the watched paths. New
+// directories are watched and the Sass files in them built.
+func (w *Watcher) created(path string) error {
+	// builds stage sprites and copies in hidden files
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		// it is already gone again
+		return nil
+	}
+	if !info.IsDir() {
+		return w.added(path)
+	}
+	return w.watchDir(path, w.added)
+}
+
+// added builds a new file. Top level Sass files are added to the partial
+// map first, files importing a partial are rebuilt as the import may have
+// been missing.
+func (w *Watcher) added(path string) error {
+	if _, ok := w.opts.PartialMap.Get(path); !ok && isImportable(filepath.Base(path)) {
+		src, ok := w.source(path, false)
+		if !ok {
+			return nil
+		}
+		// libsass lists the file compiled as an import, it is added now
+		// so files failing to build are rebuilt once they are fixed
+		w.opts.PartialMap.AddRelation(src, path)
+		log.Printf("Added: %s\n", src)
+	}
+	return w.rebuild(path)
+}
+
+// removed handles a file or directory leaving the watched paths. The CSS
+// of the top level files removed is deleted and they are dropped from the
+// partial map. Files importing a removed partial are rebuilt, reporting
+// the missing import.
+func (w *Watcher) removed(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		// editors save files by renaming another over them
+		return w.created(path)
+	}
+	w.opts.PartialMap.graph().Remove(path)
+	mains := w.opts.PartialMap.removeMains(path)
+	for _, main := range mains {
+		w.opts.PartialMap.graph().Remove(absPath(main))
+		w.opts.BArgs.forget(main)
+		files, err := w.opts.BArgs.removeOutput(main)
+		for _, f := range files {
+			log.Printf("Removed: %s\n", f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(mains) > 0 {
+		if err := w.opts.BArgs.updateManifest(); err != nil {
+			return err
+		}
+	}
+	return w.rebuild(path)
+}
+
 // testing channels that are not used for production runtime
 var rebuildMu sync.RWMutex
 var rebuildChan chan ([]string)
@@ -139,35 +531,164 @@ var doneChan chan string
 // rebuild is notified about sass file updates and looks
 // for the file in the partial map.  It also checks
 // for whether the file is a non-partial, no _ at beginning,
-// and requests the file be rebuilt directly.
+// and requests the file be rebuilt directly. Files using a changed
+// image or font, or sprites of it, are rebuilt too. The files are
+// queued, see WatchOptions.Debounce.
 func (w *Watcher) rebuild(eventFileName string) error {
-	paths, ok := w.opts.PartialMap.Get(eventFileName)
+	paths, ok := w.opts.PartialMap.topLevel(eventFileName)
+	if users := w.assetUsers(eventFileName); len(users) > 0 {
+		paths = append([]string{}, paths...)
+		for _, u := range users {
+			paths = appendUnique(paths, u)
+		}
+		ok = true
+	}
 	if !ok {
 		// This isn't an error per say, so let's ignore it
 		return nil
 		// return fmt.Errorf("partial map lookup failed: %s", eventFileName)
 	}
+	w.queue.add(paths, w.opts.Debounce, w.flush)
+	return nil
+}
+
+// flush builds the queued top level files, each one once
+func (w *Watcher) flush() {
+	paths := w.queue.take()
+	if len(paths) == 0 {
+		return
+	}
+	defer w.queue.done(w.opts.Debounce, w.flush)
 
-	go func(paths []string) {
-		rebuildMu.RLock()
-		if rebuildChan != nil {
-			rebuildChan <- paths
+	rebuildMu.RLock()
+	if rebuildChan != nil {
+		rebuildChan <- paths
+	}
+	rebuildMu.RUnlock()
+	for i := range paths {
+		// it changed again, the next batch builds it
+		if w.queue.queued(paths[i]) {
+			continue
 		}
-		rebuildMu.RUnlock()
-		for i := range paths {
-			// TODO: do this in a new goroutine
-			err := LoadAndBuild(paths[i], w.opts.BArgs, w.opts.PartialMap)
-			if err != nil {
-				w.errChan <- err
-			} else {
-				if doneChan != nil {
-					doneChan <- paths[i]
-				}
-				log.Printf("Rebuilt: %s\n", paths[i])
+		outs, err := loadAndBuildFile(paths[i], w.opts.BArgs, w.opts.PartialMap)
+		// the file may use assets in new directories
+		if err := w.watchAssets(); err != nil {
+			log.Println("filewatcher error:", err)
+		}
+		if err != nil {
+			w.errChan <- err
+		} else {
+			w.opts.LiveReload.notifyRebuilt(w.opts.BArgs, outs)
+			if doneChan != nil {
+				doneChan <- paths[i]
 			}
+			log.Printf("Rebuilt: %s\n", paths[i])
 		}
-	}(paths)
-	return nil
+	}
+	w.warnCycles(paths)
+	if err := w.opts.BArgs.updateManifest(); err != nil {
+		w.errChan <- err
+	}
+}
+
+// warnCycles logs the import cycles through the files at paths, every
+// file of a cycle is rebuilt whenever one of them changes
+func (w *Watcher) warnCycles(paths []string) {
+	rebuilt := make(map[string]bool)
+	for _, path := range paths {
+		rebuilt[absPath(path)] = true
+	}
+	for _, cycle := range w.opts.PartialMap.graph().Cycles() {
+		for _, file := range cycle {
+			if rebuilt[file] {
+				log.Printf("import cycle: %s\n", strings.Join(cycle, ", "))
+				break
+			}
+		}
+	}
+}
+
+// rebuildQueue batches the top level files to rebuild. One batch is
+// built at a time, files changed while it is built are queued for the
+// next.
+type rebuildQueue struct {
+	mu      sync.Mutex
+	pending []string
+	timer   *time.Timer
+	running bool
+	stopped bool
+}
+
+// add queues paths, flush is called once nothing has been added for
+// wait
+func (q *rebuildQueue) add(paths []string, wait time.Duration, flush func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, path := range paths {
+		q.pending = appendUnique(q.pending, path)
+	}
+	if !q.running {
+		q.schedule(wait, flush)
+	}
+}
+
+func (q *rebuildQueue) schedule(wait time.Duration, flush func()) {
+	if q.stopped {
+		return
+	}
+	if q.timer == nil {
+		q.timer = time.AfterFunc(wait, flush)
+		return
+	}
+	q.timer.Reset(wait)
+}
+
+// take starts a batch of the queued files, nothing is returned while
+// another batch is running
+func (q *rebuildQueue) take() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running || q.stopped || len(q.pending) == 0 {
+		return nil
+	}
+	paths := q.pending
+	q.pending = nil
+	q.running = true
+	return paths
+}
+
+// queued reports whether path is waiting for the next batch
+func (q *rebuildQueue) queued(path string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, p := range q.pending {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// done finishes the running batch, files queued meanwhile are built
+// after wait
+func (q *rebuildQueue) done(wait time.Duration, flush func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running = false
+	if len(q.pending) > 0 {
+		q.schedule(wait, flush)
+	}
+}
+
+// stop drops the queued files, no more batches are started
+func (q *rebuildQueue) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stopped = true
+	q.pending = nil
+	if q.timer != nil {
+		q.timer.Stop()
+	}
 }
 
 func appendUnique(slice []string, s string) []string {
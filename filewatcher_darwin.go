@@ -6,6 +6,7 @@ import (
 	"log"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsevents"
@@ -18,9 +19,16 @@ import (
 // Dirs contains all directories that have top level files.
 // GlobalBuildArgs contains build args that apply to all sass files.
 type Watcher struct {
+	// mu guards paths and started, builds add the directories of assets
+	mu      sync.Mutex
+	paths   []string
+	started bool
+	// esMu serializes starting and stopping es
+	esMu    sync.Mutex
 	es      *fsevents.EventStream
 	opts    *WatchOptions
 	errChan chan error
+	queue   rebuildQueue
 	closing chan struct{}
 	closed  chan struct{}
 }
@@ -31,6 +39,8 @@ func (w *Watcher) Init() {
 		w.es.Stop()
 	}
 	w.closing = make(chan struct{})
+	w.paths = nil
+	w.started = false
 	w.es = &fsevents.EventStream{
 		Latency: 500 * time.Millisecond,
 		Flags:   fsevents.FileEvents,
@@ -38,7 +48,13 @@ func (w *Watcher) Init() {
 }
 
 func (w *Watcher) startWatching() {
+	w.esMu.Lock()
+	w.mu.Lock()
+	w.es.Paths = append([]string(nil), w.paths...)
+	w.started = true
+	w.mu.Unlock()
 	w.es.Start()
+	w.esMu.Unlock()
 	for {
 		select {
 		case <-w.closing:
@@ -47,7 +63,11 @@ func (w *Watcher) startWatching() {
 		case msg := <-w.es.Events:
 			for _, event := range msg {
 				ext := filepath.Ext(event.Path)
-				if ext == ".scss" || ext == ".sass" {
+				if strings.HasPrefix(event.Path, "/private") {
+					event.Path = strings.TrimPrefix(event.Path, "/private")
+				}
+				if ext == ".scss" || ext == ".sass" ||
+					len(w.opts.PartialMap.assetUsers(event.Path)) > 0 {
 					if !checkFlag(event.Flags) {
 						log.Println("ignoring fsevent", event.Flags, "on", event.Path)
 						continue
@@ -56,11 +76,15 @@ func (w *Watcher) startWatching() {
 						watcherChan <- event.Path
 						return
 					}
-					if strings.HasPrefix(event.Path, "/private") {
-						event.Path = strings.TrimPrefix(event.Path, "/private")
+					var err error
+					switch {
+					case event.Flags&(fsevents.ItemRemoved|fsevents.ItemRenamed) != 0:
+						err = w.removed(event.Path)
+					case event.Flags&fsevents.ItemCreated != 0:
+						err = w.created(event.Path)
+					default:
+						err = w.rebuild(event.Path)
 					}
-
-					err := w.rebuild(event.Path)
 					if err != nil {
 						log.Println("rebuild error:", err)
 					}
@@ -91,22 +115,57 @@ func checkFlag(e fsevents.EventFlags) bool {
 	return false
 }
 
+// watch adds fpath to the paths of the stream. The paths of a started
+// stream are fixed, so it is restarted to pick up new paths.
 func (w *Watcher) watch(fpath string) error {
-	if len(fpath) > 0 {
-
-		w.es.Paths = appendUnique(w.es.Paths, fpath)
+	if len(fpath) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	n := len(w.paths)
+	w.paths = appendUnique(w.paths, fpath)
+	restart := w.started && len(w.paths) > n
+	w.mu.Unlock()
+	if restart {
+		// Stopping waits for pending events to be received, so the
+		// event loop, which may be calling watch, must keep running
+		go w.restart()
 	}
 	return nil
 }
 
+// restart recreates the stream with the current paths, resuming from
+// the last event received so no changes are missed
+func (w *Watcher) restart() {
+	w.esMu.Lock()
+	defer w.esMu.Unlock()
+	select {
+	case <-w.closing:
+		return
+	default:
+	}
+	w.mu.Lock()
+	paths := append([]string(nil), w.paths...)
+	w.mu.Unlock()
+	// Paths are only added, an earlier restart may have seen them all
+	if len(paths) == len(w.es.Paths) {
+		return
+	}
+	w.es.Paths = paths
+	w.es.Restart()
+}
+
 // Close shuts down the fsevent stream
 func (w *Watcher) Close() error {
+	w.queue.stop()
 	close(w.closing)
 	if w.closed != nil {
 		<-w.closed
 	}
+	w.esMu.Lock()
 	if w.es != nil {
 		w.es.Stop()
 	}
+	w.esMu.Unlock()
 	return nil
 }
@@ -18,6 +18,7 @@ type Watcher struct {
 	fw      *fsnotify.Watcher
 	opts    *WatchOptions
 	errChan chan error
+	queue   rebuildQueue
 	closing chan struct{}
 	closed  chan struct{}
 }
@@ -44,11 +45,17 @@ func (w *Watcher) startWatching() {
 				watcherChan <- event.Name
 				return
 			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				err := w.rebuild(event.Name)
-				if err != nil {
-					log.Println("rebuild error:", err)
-				}
+			var err error
+			switch {
+			case event.Op&fsnotify.Create == fsnotify.Create:
+				err = w.created(event.Name)
+			case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+				err = w.removed(event.Name)
+			case event.Op&fsnotify.Write == fsnotify.Write:
+				err = w.rebuild(event.Name)
+			}
+			if err != nil {
+				log.Println("rebuild error:", err)
 			}
 		case err := <-w.fw.Errors:
 			if err != nil {
@@ -69,6 +76,7 @@ func (w *Watcher) watch(fpath string) error {
 
 // Close shuts down the fsevent stream
 func (w *Watcher) Close() error {
+	w.queue.stop()
 	close(w.closing)
 	if w.closed != nil {
 		<-w.closed
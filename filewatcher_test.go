@@ -1,11 +1,14 @@
 package wellington
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -263,3 +266,393 @@ func TestAppendUnique(t *testing.T) {
 		t.Errorf("got: %d wanted: %d", len(new), len(lst)+1)
 	}
 }
+
+func TestWatch_createRemove(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "watch_create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	bdir := filepath.Join(tdir, "build")
+	bArgs := &BuildArgs{
+		BuildDir: bdir,
+		RTL:      true,
+		Exclude:  []string{"skip.scss"},
+	}
+	bArgs.WithPaths([]string{sdir})
+	pmap := NewPartialMap()
+	w, err := NewWatcher(&WatchOptions{
+		Paths:      []string{sdir},
+		PartialMap: pmap,
+		BArgs:      bArgs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	doneChanMu.Lock()
+	doneChan = make(chan string, 1)
+	doneChanMu.Unlock()
+	defer func() {
+		doneChanMu.Lock()
+		doneChan = nil
+		doneChanMu.Unlock()
+	}()
+
+	// A new directory is built with the files in it
+	sub := filepath.Join(sdir, "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(sub, "new.scss")
+	files := map[string]string{
+		path:                            "div { float: left; }",
+		filepath.Join(sub, "skip.scss"): "div { color: red; }",
+		filepath.Join(sub, "_p.scss"):   "div { color: red; }",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.created(sub); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-doneChan:
+		if p != path {
+			t.Errorf("got: %s wanted: %s", p, path)
+		}
+	case err := <-w.errChan:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for build")
+	}
+	if mains, _ := pmap.Get(path); len(mains) != 1 || mains[0] != path {
+		t.Errorf("got: %v wanted: %s in the partial map", mains, path)
+	}
+	if _, ok := pmap.Get(filepath.Join(sub, "skip.scss")); ok {
+		t.Error("excluded file was added")
+	}
+	out := filepath.Join(bdir, "sub", "new.css")
+	for _, name := range []string{out, filepath.Join(bdir, "sub", "new.rtl.css")} {
+		if _, err := os.Stat(name); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Removing it removes its CSS
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.removed(path); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := ioutil.ReadDir(filepath.Join(bdir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) > 0 {
+		t.Errorf("CSS was kept: %s", infos[0].Name())
+	}
+	if _, ok := pmap.Get(path); ok {
+		t.Errorf("%s was kept in the partial map", path)
+	}
+}
+
+func TestRebuild_assets(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "rebuild_assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir, idir := filepath.Join(tdir, "sass"), filepath.Join(tdir, "img")
+	for _, dir := range []string{sdir, idir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bs, err := ioutil.ReadFile("test/img/139.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(idir, "a.png"), bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"sprite.scss": `$map: sprite-map("*.png");
+div { background: sprite($map, "a"); }`,
+		"width.scss": `div { width: image-width("a.png"); }`,
+		"plain.scss": `div { color: red; }`,
+	}
+	for name, contents := range files {
+		err := ioutil.WriteFile(filepath.Join(sdir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gen := filepath.Join(tdir, "gen")
+	bArgs := &BuildArgs{
+		BuildDir: filepath.Join(tdir, "build"),
+		ImageDir: idir,
+		Gen:      gen,
+	}
+	bArgs.WithPaths([]string{sdir})
+	pmap := NewPartialMap()
+	if err := NewBuild(bArgs, pmap).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := filepath.Join(sdir, "sprite.scss")
+	width := filepath.Join(sdir, "width.scss")
+	tests := []struct {
+		path  string
+		users []string
+	}{
+		{filepath.Join(idir, "a.png"), []string{sprite, width}},
+		// new images are added to the sprite
+		{filepath.Join(idir, "b.png"), []string{sprite}},
+		{filepath.Join(idir, "b.gif"), nil},
+		// sprites and hashed copies are written by builds
+		{filepath.Join(gen, "1a2b3c.png"), nil},
+		{filepath.Join(idir, "a.1a2b3c4d.png"), nil},
+	}
+	w, err := NewWatcher(&WatchOptions{
+		Paths:      []string{sdir},
+		PartialMap: pmap,
+		BArgs:      bArgs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for _, test := range tests {
+		if got := w.assetUsers(test.path); !reflect.DeepEqual(got, test.users) {
+			t.Errorf("%s got: %v wanted: %v", test.path, got, test.users)
+		}
+	}
+
+	// Changing the image rebuilds the sprite
+	rebuildMu.Lock()
+	rebuildChan = make(chan []string, 1)
+	rebuildMu.Unlock()
+	defer func() {
+		rebuildMu.Lock()
+		rebuildChan = nil
+		rebuildMu.Unlock()
+	}()
+	if err := w.rebuild(filepath.Join(idir, "b.png")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case paths := <-rebuildChan:
+		if e := []string{sprite}; !reflect.DeepEqual(paths, e) {
+			t.Errorf("got: %v wanted: %v", paths, e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for rebuild")
+	}
+}
+
+func TestRebuild_debounce(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "rebuild_debounce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	pmap := NewPartialMap()
+	pmap.Add("_a.scss", []string{"one.scss", "two.scss"})
+	pmap.Add("_b.scss", []string{"two.scss", "three.scss"})
+	w, err := NewWatcher(&WatchOptions{
+		PartialMap: pmap,
+		BArgs:      &BuildArgs{BuildDir: tdir},
+		Debounce:   50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	rebuildMu.Lock()
+	rebuildChan = make(chan []string, 2)
+	rebuildMu.Unlock()
+	defer func() {
+		rebuildMu.Lock()
+		rebuildChan = nil
+		rebuildMu.Unlock()
+	}()
+
+	// Changes within the window are built together
+	for _, name := range []string{"_a.scss", "_b.scss", "_a.scss"} {
+		if err := w.rebuild(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case paths := <-rebuildChan:
+		if e := []string{"one.scss", "two.scss", "three.scss"}; !reflect.DeepEqual(paths, e) {
+			t.Errorf("got: %v wanted: %v", paths, e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for rebuild")
+	}
+	select {
+	case paths := <-rebuildChan:
+		t.Errorf("rebuilt again: %v", paths)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRebuildQueue(t *testing.T) {
+	var q rebuildQueue
+	flushed := make(chan struct{}, 2)
+	flush := func() { flushed <- struct{}{} }
+
+	q.add([]string{"a", "b"}, 0, flush)
+	<-flushed
+	if got := q.take(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got: %v wanted: [a b]", got)
+	}
+
+	// Files changed during a batch wait for it to finish
+	q.add([]string{"b"}, 0, flush)
+	if !q.queued("b") || q.queued("a") {
+		t.Error("b was not queued")
+	}
+	if got := q.take(); got != nil {
+		t.Errorf("got: %v while running", got)
+	}
+	select {
+	case <-flushed:
+		t.Error("flushed while running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	q.done(0, flush)
+	<-flushed
+	if got := q.take(); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("got: %v wanted: [b]", got)
+	}
+	q.done(0, flush)
+
+	q.stop()
+	q.add([]string{"c"}, 0, flush)
+	if got := q.take(); got != nil {
+		t.Errorf("got: %v after stop", got)
+	}
+}
+
+func TestRebuild_manifest(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "rebuild_manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(sdir, "main.scss")
+	other := filepath.Join(sdir, "other.scss")
+	for _, path := range []string{main, other} {
+		if err := ioutil.WriteFile(path, []byte("div { color: red; }"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(tdir, "build", "manifest.json")
+	bArgs := &BuildArgs{
+		BuildDir: filepath.Join(tdir, "build"),
+		Manifest: path,
+	}
+	bArgs.WithPaths([]string{sdir})
+	pmap := NewPartialMap()
+	if err := NewBuild(bArgs, pmap).Run(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := m.Files[0].Hash
+
+	w, err := NewWatcher(&WatchOptions{
+		Paths:      []string{sdir},
+		PartialMap: pmap,
+		BArgs:      bArgs,
+		Debounce:   time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Rebuilt files are updated in the manifest
+	if err := ioutil.WriteFile(main, []byte("div { color: blue; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.rebuild(main); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for m.Files[0].Hash == hash {
+		select {
+		case err := <-w.errChan:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatal("timeout waiting for the manifest")
+		case <-time.After(10 * time.Millisecond):
+		}
+		if m, err = ReadManifest(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e := 2; len(m.Files) != e {
+		t.Errorf("got: %d wanted: %d", len(m.Files), e)
+	}
+
+	// Removed files are dropped from it
+	if err := os.Remove(main); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.removed(main); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = ReadManifest(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Input != "../sass/other.scss" {
+		t.Errorf("got: %v wanted only other.scss", m.Files)
+	}
+}
+
+func TestWatcher_warnCycles(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	a, b, c := absPath("a.scss"), absPath("b.scss"), absPath("c.scss")
+	pmap := NewPartialMap()
+	pmap.setImports(a, []string{a, b})
+	pmap.setImports(b, []string{b, a})
+	pmap.setImports(c, []string{c, b})
+	w := &Watcher{opts: &WatchOptions{PartialMap: pmap}}
+
+	w.warnCycles([]string{c})
+	if buf.Len() > 0 {
+		t.Errorf("got: %s wanted no warning", buf.String())
+	}
+	w.warnCycles([]string{"b.scss"})
+	if e := "import cycle: " + a + ", " + b; !strings.Contains(buf.String(), e) {
+		t.Errorf("got: %s wanted: %s", buf.String(), e)
+	}
+}
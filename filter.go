@@ -0,0 +1,108 @@
+package wellington
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile lists patterns of files and directories to skip when
+// looking for Sass files, one per line. It is read from the root of
+// every path searched.
+const IgnoreFile = ".wtignore"
+
+// sourceFilter decides which Sass files are found in a directory.
+// Patterns containing a slash match the path relative to the directory
+// searched, ** matches any number of directories. Other patterns match
+// the name of any file or directory.
+type sourceFilter struct {
+	include []string
+	exclude []string
+}
+
+// newSourceFilter creates a filter for the directory root, adding the
+// patterns from its ignore file to exclude
+func newSourceFilter(root string, include, exclude []string) (*sourceFilter, error) {
+	f := &sourceFilter{
+		include: include,
+		exclude: append([]string{}, exclude...),
+	}
+
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return f, nil
+	}
+	fh, err := os.Open(filepath.Join(root, IgnoreFile))
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f.exclude = append(f.exclude, line)
+	}
+	return f, scanner.Err()
+}
+
+// skip reports whether rel, a slash separated path relative to the
+// directory searched, should not be built. Directories are only
+// checked against the exclude patterns.
+func (f *sourceFilter) skip(rel string, dir bool) bool {
+	for _, pattern := range f.exclude {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+	if dir || len(f.include) == 0 {
+		return false
+	}
+	for _, pattern := range f.include {
+		if matchPattern(pattern, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPattern matches rel against a single filter pattern
+func matchPattern(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	return matchParts(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchParts matches path elements against pattern elements, ** matches
+// zero or more elements
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
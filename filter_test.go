@@ -0,0 +1,98 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		match        bool
+	}{
+		{"node_modules", "node_modules", true},
+		{"node_modules", "a/node_modules", true},
+		{"*_test.scss", "a/b_test.scss", true},
+		{"vendor/**", "vendor", true},
+		{"vendor/**", "vendor/theme/main.scss", true},
+		{"vendor/**", "a/vendor/main.scss", false},
+		{"**/fixtures/**", "a/b/fixtures/main.scss", true},
+		{"/pages/*.scss", "pages/home.scss", true},
+		{"pages/*.scss", "pages/sub/home.scss", false},
+		{"themes/", "themes", true},
+	}
+	for _, test := range tests {
+		if got := matchPattern(test.pattern, test.rel); got != test.match {
+			t.Errorf("%s %s got: %t wanted: %t",
+				test.pattern, test.rel, got, test.match)
+		}
+	}
+}
+
+func TestPathsToFiles_filter(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testpaths_filter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	files := []string{
+		"main.scss",
+		"pages/home.scss",
+		"pages/about.scss",
+		"node_modules/lib/lib.scss",
+		"vendor/theme.scss",
+		"fixtures/broken.scss",
+	}
+	for _, file := range files {
+		path := filepath.Join(tdir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(tdir, IgnoreFile),
+		[]byte("# test fixtures\nfixtures/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rels := func(paths []string) []string {
+		var rels []string
+		for _, path := range paths {
+			rel, _ := filepath.Rel(tdir, path)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		sort.Strings(rels)
+		return rels
+	}
+
+	got := rels(pathsToFiles([]string{tdir}, true, nil,
+		[]string{"node_modules", "vendor/**"}))
+	e := []string{"main.scss", "pages/about.scss", "pages/home.scss"}
+	if len(got) != len(e) {
+		t.Fatalf("got: %v wanted: %v", got, e)
+	}
+	for i := range e {
+		if got[i] != e[i] {
+			t.Errorf("got: %s wanted: %s", got[i], e[i])
+		}
+	}
+
+	got = rels(pathsToFiles([]string{tdir}, true,
+		[]string{"pages/**"}, []string{"about.scss"}))
+	if len(got) != 1 || got[0] != "pages/home.scss" {
+		t.Errorf("got: %v wanted: [pages/home.scss]", got)
+	}
+
+	// Files passed directly are always built
+	file := filepath.Join(tdir, "fixtures", "broken.scss")
+	if got := pathsToFiles([]string{file}, true, nil, nil); len(got) != 1 {
+		t.Errorf("got: %v wanted: [%s]", got, file)
+	}
+}
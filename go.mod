@@ -3,6 +3,7 @@ module github.com/wellington/wellington
 go 1.11
 
 require (
+	github.com/andybalholm/brotli v1.0.6
 	github.com/fatih/color v1.9.0
 	github.com/fsnotify/fsevents v0.1.1
 	github.com/mattn/go-colorable v0.1.6 // indirect
@@ -12,4 +13,5 @@ require (
 	github.com/wellington/spritewell v0.5.0
 	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
 	gopkg.in/fsnotify.v1 v1.4.7
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -32,6 +32,10 @@ func init() {
 // find non-obvious dependency failures like mismatched dependencies.
 var ErrPayloadNil = errors.New("payload is nil")
 
+// ErrHashedBuildDir is returned for filename cache busting without a
+// build directory, the hashed copies would be written to the sources.
+var ErrHashedBuildDir = errors.New("filename cachebust requires a build directory")
+
 // ImageURL handles calls to resolve the path to a local image from the
 // built css file path.
 func ImageURL(ctx context.Context, csv libsass.SassValue) (*libsass.SassValue, error) {
@@ -54,19 +58,32 @@ func ImageURL(ctx context.Context, csv libsass.SassValue) (*libsass.SassValue, e
 	imgdir := pather.ImgDir()
 
 	abspath := filepath.Join(imgdir, path[0])
+	useAsset(comp.Payload(), abspath)
 	method := comp.CacheBust()
 
-	qry, err := qs(method, abspath)
+	name := path[0]
+	dir := pather.ImgDir()
+	var qry string
+	if method == "filename" {
+		// the copy is written with the CSS, out of the source tree
+		dir = pather.BuildDir()
+		if len(dir) == 0 {
+			return nil, ErrHashedBuildDir
+		}
+		name, err = hashedName(comp.Payload(), abspath, name, dir)
+	} else {
+		qry, err = qs(method, abspath)
+	}
 	if err != nil {
 		return nil, err
 	}
-	rel, err := relativeImage(pather.BuildDir(), pather.ImgDir())
+	rel, err := relativeImage(pather.BuildDir(), dir)
 	if err != nil {
 		return nil, err
 	}
 	url := strings.Join([]string{
 		rel,
-		path[0],
+		name,
 	}, "/")
 	res, err := libsass.Marshal(fmt.Sprintf("url('%s%s')", url, qry))
 	if err != nil {
@@ -128,6 +145,7 @@ func ImageHeight(mainctx context.Context, usv libsass.SassValue) (*libsass.SassV
 	}
 
 	if len(glob) == 0 {
+		useAsset(loadctx, filepath.Join(paths.ImgDir(), name))
 		exst := images.Get(name)
 		if exst != nil {
 			imgs = exst
@@ -191,6 +209,7 @@ func ImageWidth(mainctx context.Context, usv libsass.SassValue) (rsv *libsass.Sa
 	var images payload.Payloader
 
 	if len(glob) == 0 {
+		useAsset(loadctx, filepath.Join(paths.ImgDir(), name))
 		images = payload.Image(loadctx)
 		hit := images.Get(name)
 		if hit != nil {
@@ -274,6 +293,7 @@ func InlineImage(mainctx context.Context, usv libsass.SassValue) (rsv *libsass.S
 	if err == nil && len(u.Scheme) > 0 {
 		f, err = imgResolver.Do(u.String())
 	} else {
+		useAsset(comp.Payload(), filepath.Join(paths.ImgDir(), name))
 		f, err = os.Open(filepath.Join(paths.ImgDir(), name))
 	}
 	if err != nil {
@@ -407,7 +407,7 @@ div {
 
 	// Output:
 	// div {
-	//   background: url("img/b9d55f.png") 0px -149px; }
+	//   background: url("img/1d348d.png") 0px -149px; }
 }
 
 func TestHandle_offset(t *testing.T) {
@@ -425,7 +425,7 @@ div {
 	}
 
 	e := `div {
-  background: url("img/b9d55f.png") 10px -139px; }
+  background: url("img/1d348d.png") 10px -139px; }
 `
 	if e != out.String() {
 		t.Errorf("got:\n%s\nwanted:\n%s", out.String(), e)
@@ -486,7 +486,7 @@ div {
 	}
 
 	e := `div {
-  background: url("http://foo.com/build/b9d55f.png") 0px -149px; }
+  background: url("http://foo.com/build/1d348d.png") 0px -149px; }
 `
 	if e != out.String() {
 		t.Errorf("got:\n%s\nwanted:\n%s", out.String(), e)
@@ -513,11 +513,11 @@ div {
 	}
 
 	e := `div {
-  background: url("img/e15654.png") 0px 0px;
-  background: url("img/e15654.png") 0px -150px;
-  background: url("img/e15654.png") 0px -300px;
-  background: url("img/e15654.png") 0px -450px;
-  background: url("img/e15654.png") 0px -600px; }
+  background: url("img/94eb73.png") 0px 0px;
+  background: url("img/94eb73.png") 0px -150px;
+  background: url("img/94eb73.png") 0px -300px;
+  background: url("img/94eb73.png") 0px -450px;
+  background: url("img/94eb73.png") 0px -600px; }
 `
 
 	if out.String() != e {
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"hash/crc32"
 	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"golang.org/x/net/context"
 
 	libsass "github.com/wellington/go-libsass"
+	"github.com/wellington/wellington/payload"
 )
 
 func init() {
@@ -44,11 +48,6 @@ func FontURL(ctx context.Context, usv libsass.SassValue) (*libsass.SassValue, er
 		return nil, errors.New(s)
 	}
 
-	rel, err := filepath.Rel(paths.BuildDir(), fdir)
-	if err != nil {
-		return nil, err
-	}
-
 	if raw {
 		format = "%s%s"
 	} else {
@@ -56,7 +55,21 @@ func FontURL(ctx context.Context, usv libsass.SassValue) (*libsass.SassValue, er
 	}
 
 	abspath := filepath.Join(fdir, path)
-	qry, err := qs(comp.CacheBust(), abspath)
+	useAsset(comp.Payload(), abspath)
+	var qry, rel string
+	if method := comp.CacheBust(); method == "filename" {
+		// the copy is written with the CSS, out of the source tree
+		if len(paths.BuildDir()) == 0 {
+			return nil, ErrHashedBuildDir
+		}
+		rel = "."
+		path, err = hashedName(comp.Payload(), abspath, path, paths.BuildDir())
+	} else {
+		rel, err = filepath.Rel(paths.BuildDir(), fdir)
+		if err == nil {
+			qry, err = qs(method, abspath)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +110,80 @@ func modHash(info os.FileInfo) (string, error) {
 	return "?" + fmt.Sprintf("%x", ts[:4]), nil
 }
 
+// hashedAsset returns the path of a copy of the file at abs named after
+// its content ie. image.1a2b3c4d.png. The copy is written to dir and
+// recorded in the payload, the copy of an older version of abs it
+// replaces in dir is removed once the content of abs changed.
+func hashedAsset(ctx context.Context, abs, dir string) (string, error) {
+	bs, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	sum := fmt.Sprintf("%x", sha1.Sum(bs))[:8]
+	ext := filepath.Ext(abs)
+	hashed := filepath.Join(dir,
+		strings.TrimSuffix(filepath.Base(abs), ext)+"."+sum+ext)
+
+	if _, err := os.Stat(hashed); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		// Write to a temporary file, other compiles may be creating
+		// the same copy
+		f, err := ioutil.TempFile(dir, ".wt-")
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(bs)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), hashed)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+	}
+
+	if ctx != nil {
+		if m := payload.Hashed(ctx); m != nil {
+			if old := m.Set(abs, hashed); len(old) > 0 && old != hashed {
+				os.Remove(old)
+			}
+		}
+	}
+	return hashed, nil
+}
+
+// useAsset records the file, or sprite glob, at path as used by the
+// compile if its payload collects them, see payload.WithAssets
+func useAsset(ctx context.Context, path string) {
+	if ctx == nil {
+		return
+	}
+	if l := payload.Assets(ctx); l != nil {
+		l.Add(path)
+	}
+}
+
+// hashedName writes the content hashed copy of abs to the directory of
+// the relative url name in dir, and returns the url of the copy
+// relative to dir
+func hashedName(ctx context.Context, abs, name, dir string) (string, error) {
+	sub := path.Dir(path.Clean(filepath.ToSlash(name)))
+	// urls leaving the asset directory would leave dir too
+	if sub == ".." || strings.HasPrefix(sub, "../") || path.IsAbs(sub) {
+		sub = "."
+	}
+	hashed, err := hashedAsset(ctx, abs, filepath.Join(dir, filepath.FromSlash(sub)))
+	if err != nil {
+		return "", err
+	}
+	return path.Join(sub, filepath.Base(hashed)), nil
+}
+
 func qs(method string, abs string) (string, error) {
 	var qry string
 	var err error
@@ -3,11 +3,14 @@ package handlers
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	libsass "github.com/wellington/go-libsass"
+	"github.com/wellington/wellington/payload"
 )
 
 func TestFontURLFail(t *testing.T) {
@@ -111,3 +114,75 @@ error in C function font-url: Invalid Sass type expected: string got: libs.SassN
 		t.Errorf("got:\n%s\nwanted:\n%s", err, e)
 	}
 }
+
+func TestHashedAsset(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testhashedasset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	bs, err := ioutil.ReadFile("../test/img/139.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs := filepath.Join(tdir, "139.png")
+	if err := ioutil.WriteFile(abs, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := payload.New()
+	bdir := filepath.Join(tdir, "build")
+	name, err := hashedName(ctx, abs, "sub/139.png", bdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := "sub/139.c24a3a65.png"; name != e {
+		t.Errorf("got: %s wanted: %s", name, e)
+	}
+
+	hashed, ok := payload.Hashed(ctx).Get(abs, filepath.Join(bdir, "sub"))
+	if !ok {
+		t.Fatal("hashed copy not recorded")
+	}
+	if e := filepath.Join(bdir, "sub", "139.c24a3a65.png"); hashed != e {
+		t.Errorf("got: %s wanted: %s", hashed, e)
+	}
+	cp, err := ioutil.ReadFile(hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bs, cp) {
+		t.Error("hashed copy does not match the original")
+	}
+
+	// A copy of the changed asset replaces the old one
+	if err := ioutil.WriteFile(abs, append(bs, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hashedName(ctx, abs, "sub/139.png", bdir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(hashed); !os.IsNotExist(err) {
+		t.Errorf("old copy was not removed: %v", err)
+	}
+}
+
+func TestHashedAsset_noBuildDir(t *testing.T) {
+	for _, fn := range []string{"image-url", "font-url"} {
+		in := bytes.NewBufferString(`div { background: ` + fn + `("139.png"); }`)
+		var out bytes.Buffer
+		comp, err := libsass.New(&out, in,
+			libsass.ImgDir("../test/img"),
+			libsass.FontDir("../test/img"),
+			libsass.CacheBust("filename"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = comp.Run()
+		if err == nil || !strings.Contains(err.Error(), ErrHashedBuildDir.Error()) {
+			t.Errorf("%s got: %v wanted: %s", fn, err, ErrHashedBuildDir)
+		}
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
 	"golang.org/x/net/context"
 
@@ -163,6 +165,20 @@ func Sprite(ctx context.Context, usv libsass.SassValue) (rsv *libsass.SassValue,
 	genImgDir := pather.ImgBuildDir()
 	httpPath := pather.HTTPPath()
 
+	if comp.CacheBust() == "filename" {
+		// The sprite must be on disk before its content is known
+		err := payload.WaitSprite(loadctx, imgs)
+		if err != nil {
+			return nil, err
+		}
+		hashed, err := hashedAsset(loadctx,
+			filepath.Join(genImgDir, filepath.Base(path)), genImgDir)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(filepath.Dir(path), filepath.Base(hashed))
+	}
+
 	// FIXME: path directory can not be trusted, rebuild this from the context
 	if len(httpPath) == 0 {
 		ctxPath, err := filepath.Rel(buildDir, genImgDir)
@@ -210,6 +226,29 @@ func Sprite(ctx context.Context, usv libsass.SassValue) (rsv *libsass.SassValue,
 	return &str, nil
 }
 
+// spriteStage is the directory in the generated image directory sprites
+// are written to before they are complete
+const spriteStage = ".wt-sprites"
+
+var (
+	spriteLocksMu sync.Mutex
+	spriteLocks   = make(map[string]*sync.Mutex)
+)
+
+// lockSprite serializes writing the sprite at path, the returned func
+// releases it
+func lockSprite(path string) func() {
+	spriteLocksMu.Lock()
+	mu, ok := spriteLocks[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		spriteLocks[path] = mu
+	}
+	spriteLocksMu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
 // SpriteMap returns a sprite from the passed glob and sprite
 // parameters.
 func SpriteMap(mainctx context.Context, usv libsass.SassValue) (*libsass.SassValue, error) {
@@ -224,10 +263,13 @@ func SpriteMap(mainctx context.Context, usv libsass.SassValue) (*libsass.SassVal
 		return nil, err
 	}
 	paths := comp.(libsass.Pather)
+	// The sprite is written to a staging directory and moved into
+	// place once complete, so a failure keeps the last good sprite
+	genImgDir := filepath.Join(paths.ImgBuildDir(), ".")
 	imgs := sw.New(&sw.Options{
 		ImageDir:  paths.ImgDir(),
 		BuildDir:  paths.BuildDir(),
-		GenImgDir: paths.ImgBuildDir(),
+		GenImgDir: filepath.Join(genImgDir, spriteStage),
 		Padding:   int(spacing.Value),
 	})
 	if cglob, err := strconv.Unquote(glob); err == nil {
@@ -238,6 +280,8 @@ func SpriteMap(mainctx context.Context, usv libsass.SassValue) (*libsass.SassVal
 
 	loadctx := comp.Payload()
 	sprites := payload.Sprite(loadctx)
+	// images added to the glob change the sprite
+	useAsset(loadctx, filepath.Join(paths.ImgDir(), glob))
 
 	// FIXME: wtf is this?
 	// sprites.RLock()
@@ -259,10 +303,26 @@ func SpriteMap(mainctx context.Context, usv libsass.SassValue) (*libsass.SassVal
 		return nil, err
 	}
 
-	_, err = imgs.Export()
+	out, err := imgs.OutputPath()
+	if err != nil {
+		return nil, err
+	}
+	final := filepath.Join(genImgDir, filepath.Base(out))
+	// compiles using the same sprite write the same staged file
+	unlock := lockSprite(final)
+	staged, err := imgs.Export()
 	if err != nil {
+		unlock()
 		return nil, err
 	}
+	payload.Track(loadctx, imgs, func(err error) error {
+		defer unlock()
+		if err != nil {
+			os.Remove(staged)
+			return err
+		}
+		return os.Rename(staged, final)
+	})
 
 	res, err := libsass.Marshal(key)
 	if err != nil {
@@ -3,8 +3,11 @@ package handlers
 import (
 	"fmt"
 
+	"golang.org/x/net/context"
+
 	"github.com/fatih/color"
 	libsass "github.com/wellington/go-libsass"
+	"github.com/wellington/wellington/payload"
 )
 
 // WarnHandler captures Sass warnings and redirects to stdout
@@ -17,6 +20,27 @@ func WarnHandler(v interface{}, csv libsass.SassValue, rsv *libsass.SassValue) e
 	return nil
 }
 
+// Warn is a Sass function handling @warn. Warnings are added to the
+// compile's payload if it collects them, see payload.WithWarnings,
+// otherwise they are printed like WarnHandler. It is not registered by
+// default, register it with:
+//
+//	libsass.RegisterSassFunc("@warn", handlers.Warn)
+func Warn(ctx context.Context, usv libsass.SassValue) (*libsass.SassValue, error) {
+	var s string
+	libsass.Unmarshal(usv, &s)
+	if comp, err := libsass.CompFromCtx(ctx); err == nil {
+		if l := payload.Warnings(comp.Payload()); l != nil {
+			l.Add(s)
+			res, err := libsass.Marshal("")
+			return &res, err
+		}
+	}
+	fmt.Println(color.YellowString("WARNING: " + s))
+	res, err := libsass.Marshal("")
+	return &res, err
+}
+
 func init() {
 	// libsass.RegisterHandler("@warn", WarnHandler)
 }
@@ -0,0 +1,155 @@
+package wellington
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultLiveReloadAddr is the address the livereload server listens on
+// by default, the port used by other livereload tools
+const DefaultLiveReloadAddr = ":35729"
+
+// LiveReload tells browsers when CSS is rebuilt with Server-Sent Events.
+// Pages load the client with
+//
+//	<script src="http://localhost:35729/livereload.js"></script>
+//
+// which swaps the changed stylesheets without reloading the page. The
+// events are sent from /livereload as "css changed: path", path is the
+// CSS relative to the build directory ie. css changed: sub/main.css
+type LiveReload struct {
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+}
+
+// NewLiveReload returns a LiveReload without clients
+func NewLiveReload() *LiveReload {
+	return &LiveReload{clients: make(map[chan string]struct{})}
+}
+
+// Notify sends the change of the CSS at path, relative to the build
+// directory, to every client. Clients too slow to read their events
+// miss them.
+func (lr *LiveReload) Notify(path string) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for c := range lr.clients {
+		select {
+		case c <- filepath.ToSlash(path):
+		default:
+		}
+	}
+}
+
+// ServeHTTP serves the events at /livereload and the client at
+// /livereload.js
+func (lr *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// pages are served from a different origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/livereload":
+		lr.events(w, r)
+	case "/livereload.js":
+		w.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprint(w, liveReloadJS)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// events streams changes to a client until it disconnects
+func (lr *LiveReload) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	c := make(chan string, 16)
+	lr.mu.Lock()
+	lr.clients[c] = struct{}{}
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, c)
+		lr.mu.Unlock()
+	}()
+
+	// the comment lets the client know it is connected
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case path := <-c:
+			fmt.Fprintf(w, "data: css changed: %s\n\n", path)
+			flusher.Flush()
+		}
+	}
+}
+
+// notifyRebuilt tells the LiveReload clients that outs, CSS in the build
+// directory of gba, changed
+func (lr *LiveReload) notifyRebuilt(gba *BuildArgs, outs []string) {
+	if lr == nil {
+		return
+	}
+	for _, out := range outs {
+		rel, err := filepath.Rel(gba.BuildDir, out)
+		if err != nil {
+			rel = filepath.Base(out)
+		}
+		lr.Notify(rel)
+	}
+}
+
+// liveReloadJS replaces the stylesheets linking to changed CSS, or all
+// stylesheets when none match, with a new link once it has loaded
+const liveReloadJS = `(function () {
+  var script = document.currentScript;
+  var base = script ? script.src.replace(/\/livereload\.js.*$/, "") : "";
+  var prefix = "css changed: ";
+
+  function swap(link) {
+    link.setAttribute("data-livereload-stale", "");
+    var href = link.href.replace(/([?&])livereload=\d+&?/, "$1").replace(/[?&]$/, "");
+    var next = link.cloneNode();
+    next.removeAttribute("data-livereload-stale");
+    next.href = href + (href.indexOf("?") < 0 ? "?" : "&") + "livereload=" + Date.now();
+    next.onload = function () {
+      if (link.parentNode) {
+        link.parentNode.removeChild(link);
+      }
+    };
+    link.parentNode.insertBefore(next, link.nextSibling);
+  }
+
+  var source = new EventSource(base + "/livereload");
+  source.onmessage = function (e) {
+    if (e.data.indexOf(prefix) !== 0) {
+      return;
+    }
+    var path = "/" + e.data.slice(prefix.length);
+    var links = document.querySelectorAll('link[rel="stylesheet"]:not([data-livereload-stale])');
+    var matched = [];
+    for (var i = 0; i < links.length; i++) {
+      var href = links[i].href.split("?")[0];
+      if (href.slice(-path.length) === path) {
+        matched.push(links[i]);
+      }
+    }
+    if (matched.length === 0) {
+      matched = links;
+    }
+    for (var j = 0; j < matched.length; j++) {
+      swap(matched[j]);
+    }
+  };
+})();
+`
@@ -0,0 +1,173 @@
+package wellington
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveReload(t *testing.T) {
+	lr := NewLiveReload()
+	ts := httptest.NewServer(lr)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/livereload.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(bs), "EventSource") {
+		t.Errorf("got: %q wanted the client", bs)
+	}
+
+	resp, err = http.Get(ts.URL + "/livereload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if e := "text/event-stream"; resp.Header.Get("Content-Type") != e {
+		t.Errorf("got: %s wanted: %s", resp.Header.Get("Content-Type"), e)
+	}
+	r := bufio.NewReader(resp.Body)
+	// wait for the client to be registered
+	if line, err := r.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("got: %q %v", line, err)
+	}
+	r.ReadString('\n')
+
+	lr.Notify(filepath.Join("sub", "main.css"))
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := "data: css changed: sub/main.css\n"; line != e {
+		t.Errorf("got: %q wanted: %q", line, e)
+	}
+
+	resp, err = http.Get(ts.URL + "/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got: %d wanted: 404", resp.StatusCode)
+	}
+}
+
+func TestRebuild_liveReload(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "rebuild_livereload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(filepath.Join(sdir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(sdir, "sub", "main.scss")
+	if err := ioutil.WriteFile(path, []byte("div { color: red; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lr := NewLiveReload()
+	c := make(chan string, 1)
+	lr.clients[c] = struct{}{}
+
+	pmap := NewPartialMap()
+	pmap.Add(path, []string{path})
+	bArgs := &BuildArgs{BuildDir: filepath.Join(tdir, "build")}
+	bArgs.WithPaths([]string{sdir})
+	w, err := NewWatcher(&WatchOptions{
+		PartialMap: pmap,
+		BArgs:      bArgs,
+		LiveReload: lr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.rebuild(path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-c:
+		if e := "sub/main.css"; got != e {
+			t.Errorf("got: %s wanted: %s", got, e)
+		}
+	case err := <-w.errChan:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for livereload")
+	}
+}
+
+func TestRebuild_liveReloadParts(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "rebuild_livereloadparts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(sdir, "main.scss")
+	err = ioutil.WriteFile(path, []byte("a { float: left; }\nb { float: left; }\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lr := NewLiveReload()
+	c := make(chan string, 16)
+	lr.clients[c] = struct{}{}
+
+	pmap := NewPartialMap()
+	pmap.Add(path, []string{path})
+	bArgs := &BuildArgs{
+		BuildDir:     filepath.Join(tdir, "build"),
+		MaxSelectors: 1,
+		RTL:          true,
+	}
+	bArgs.WithPaths([]string{sdir})
+	w, err := NewWatcher(&WatchOptions{
+		PartialMap: pmap,
+		BArgs:      bArgs,
+		LiveReload: lr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.rebuild(path); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for len(got) < 4 {
+		select {
+		case p := <-c:
+			got = append(got, p)
+		case err := <-w.errChan:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for livereload, got: %v", got)
+		}
+	}
+	sort.Strings(got)
+	e := []string{"main-1.css", "main.css", "main.rtl-1.css", "main.rtl.css"}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+}
@@ -0,0 +1,391 @@
+package wellington
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/wellington/spritewell"
+	"github.com/wellington/wellington/payload"
+)
+
+// ErrManifestBuildDir is returned when a manifest is requested for a
+// build writing to stdout
+var ErrManifestBuildDir = errors.New("manifest requires a build directory")
+
+// Manifest describes the files produced by a Build. All paths are
+// relative to the directory containing the manifest.
+type Manifest struct {
+	Files   []ManifestFile   `json:"files"`
+	Sprites []ManifestSprite `json:"sprites"`
+	// Assets are the content hashed copies of images, fonts and
+	// sprites used when cache busting by file name
+	Assets []ManifestAsset `json:"assets,omitempty"`
+}
+
+// ManifestFile records a compiled Sass file
+type ManifestFile struct {
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+	SourceMap string `json:"sourcemap,omitempty"`
+	// Hash is the hex encoded sha1 of the output
+	Hash    string   `json:"hash"`
+	Size    int64    `json:"size"`
+	Imports []string `json:"imports"`
+	// Parts are the files Output and RTL were split into, see
+	// BuildArgs.MaxSelectors
+	Parts []string `json:"parts,omitempty"`
+	// RTL is the mirrored copy of Output, see BuildArgs.RTL
+	RTL string `json:"rtl,omitempty"`
+}
+
+// ManifestSprite records a sprite generated by sprite-map
+type ManifestSprite struct {
+	// Name is the key of the sprite in the payload
+	Name   string `json:"name"`
+	Output string `json:"output"`
+	Hash   string `json:"hash"`
+	Size   int64  `json:"size"`
+}
+
+// ManifestAsset maps an asset to its content hashed copy
+type ManifestAsset struct {
+	Source string `json:"source"`
+	Output string `json:"output"`
+}
+
+// key identifies the copy of an asset, outputs in different
+// directories each have their own copy
+func (a ManifestAsset) key() string {
+	return a.Source + "\x00" + path.Dir(a.Output)
+}
+
+// ReadManifest loads a manifest written by a Build
+func ReadManifest(path string) (*Manifest, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	err = json.Unmarshal(bs, m)
+	return m, err
+}
+
+// hashFile returns the hex encoded sha1 and size of the file at path
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), n, nil
+}
+
+// manifestDir returns the absolute directory of the manifest
+func (b *BuildArgs) manifestDir() string {
+	dir := filepath.Dir(b.Manifest)
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// manifestPath makes path relative to the manifest directory
+func manifestPath(dir, path string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return filepath.ToSlash(abs)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// manifestStore collects the entries of a manifest. BuildArgs writing
+// to the same manifest share one, see ShareManifests.
+type manifestStore struct {
+	mu    sync.Mutex
+	files map[string]manifestEntry
+	// args are the BuildArgs whose sprites and assets are listed
+	args []*BuildArgs
+	// cached is set once a build skipped files with the cache
+	cached bool
+	// prev is the manifest on disk before the first build, nil if
+	// there was none, see Prune
+	prev   *Manifest
+	loaded bool
+}
+
+// manifestEntry is a ManifestFile and the BuildArgs that built it
+type manifestEntry struct {
+	ManifestFile
+	owner *BuildArgs
+}
+
+func newManifestStore() *manifestStore {
+	return &manifestStore{files: make(map[string]manifestEntry)}
+}
+
+// ShareManifests gives BuildArgs writing to the same manifest a single
+// store of its entries, so each of them writes the files built by all
+// of them.
+func ShareManifests(args ...*BuildArgs) {
+	shared := make(map[string]*manifestStore)
+	for _, gba := range args {
+		if len(gba.Manifest) == 0 {
+			continue
+		}
+		path := absPath(gba.Manifest)
+		if _, ok := shared[path]; !ok {
+			shared[path] = newManifestStore()
+		}
+		gba.manifest = shared[path]
+	}
+}
+
+// beginManifest drops the entries a previous build with b recorded.
+// Builds using a cache keep the sprites and assets of the last
+// manifest, see keepManifest. The manifest on disk is read by the first
+// of the builds sharing it, or again when b builds again.
+func (b *BuildArgs) beginManifest() {
+	if b.manifest == nil {
+		b.manifest = newManifestStore()
+	}
+	s := b.manifest
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(b.Cache) > 0 {
+		s.cached = true
+	}
+	again := false
+	for _, a := range s.args {
+		again = again || a == b
+	}
+	if !again {
+		s.args = append(s.args, b)
+	}
+	if !s.loaded || again {
+		s.loaded = true
+		s.prev, _ = ReadManifest(b.Manifest)
+	}
+	for in, e := range s.files {
+		if e.owner == b {
+			delete(s.files, in)
+		}
+	}
+}
+
+// record adds out, the compiled output of path built with fa, its RTL
+// copy and the parts they were split into to the manifest
+func (b *BuildArgs) record(path, out, rtl string, parts, imports []string, fa *BuildArgs) error {
+	hash, size, err := hashFile(out)
+	if err != nil {
+		return err
+	}
+
+	dir := b.manifestDir()
+	mf := ManifestFile{
+		Input:   manifestPath(dir, path),
+		Output:  manifestPath(dir, out),
+		Hash:    hash,
+		Size:    size,
+		Imports: []string{},
+	}
+	if fa.SourceMap && len(parts) == 0 {
+		mf.SourceMap = manifestPath(dir, fa.outPath(path)+".map")
+	}
+	for _, part := range parts {
+		mf.Parts = append(mf.Parts, manifestPath(dir, part))
+	}
+	if len(rtl) > 0 {
+		mf.RTL = manifestPath(dir, rtl)
+	}
+	for _, imp := range imports {
+		// Builtin imports ie. compass are not files
+		if !filepath.IsAbs(imp) {
+			continue
+		}
+		mf.Imports = append(mf.Imports, manifestPath(dir, imp))
+	}
+
+	s := b.manifest
+	s.mu.Lock()
+	s.files[mf.Input] = manifestEntry{ManifestFile: mf, owner: b}
+	s.mu.Unlock()
+	return nil
+}
+
+// forget removes the entry of path, a Sass file that no longer exists,
+// from the manifest
+func (b *BuildArgs) forget(path string) {
+	if b.manifest == nil {
+		return
+	}
+	s := b.manifest
+	s.mu.Lock()
+	delete(s.files, manifestPath(b.manifestDir(), path))
+	s.mu.Unlock()
+}
+
+// updateManifest writes the manifest again after files built by a
+// Build run with b changed, if the Build wrote one
+func (b *BuildArgs) updateManifest() error {
+	if len(b.Manifest) == 0 || b.manifest == nil {
+		return nil
+	}
+	return b.writeManifest()
+}
+
+// writeManifest waits for all sprites to be written then saves the
+// entries of every build sharing the manifest to disk
+func (b *BuildArgs) writeManifest() error {
+	s := b.manifest
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.args {
+		if err := payload.Wait(a.Payload); err != nil {
+			return err
+		}
+	}
+
+	dir := b.manifestDir()
+	m := Manifest{
+		Files:   make([]ManifestFile, 0, len(s.files)),
+		Sprites: []ManifestSprite{},
+	}
+	for _, e := range s.files {
+		m.Files = append(m.Files, e.ManifestFile)
+	}
+
+	// BuildArgs may share a payload and so its sprites and assets
+	sprites := make(map[string]bool)
+	assets := make(map[string]bool)
+	var err error
+	for _, gba := range s.args {
+		payload.Sprite(gba.Payload).ForEach(func(key string, sprite *spritewell.Sprite) {
+			if err != nil {
+				return
+			}
+			var rel string
+			rel, err = sprite.OutputPath()
+			if err != nil {
+				return
+			}
+			out := filepath.Join(gba.Gen, filepath.Base(rel))
+			ms := ManifestSprite{
+				Name:   key,
+				Output: manifestPath(dir, out),
+			}
+			if sprites[ms.Output] {
+				return
+			}
+			sprites[ms.Output] = true
+			ms.Hash, ms.Size, err = hashFile(out)
+			m.Sprites = append(m.Sprites, ms)
+		})
+		if err != nil {
+			return err
+		}
+
+		if hashed := payload.Hashed(gba.Payload); hashed != nil {
+			hashed.ForEach(func(src, out string) {
+				ma := ManifestAsset{
+					Source: manifestPath(dir, src),
+					Output: manifestPath(dir, out),
+				}
+				if !assets[ma.key()] {
+					assets[ma.key()] = true
+					m.Assets = append(m.Assets, ma)
+				}
+			})
+		}
+	}
+	if s.cached {
+		// Files skipped by the cache did not request their sprites or
+		// assets, keep them from the last manifest
+		keepManifest(&m, dir, b.Manifest)
+	}
+
+	sort.Slice(m.Assets, func(i, j int) bool {
+		if m.Assets[i].Source != m.Assets[j].Source {
+			return m.Assets[i].Source < m.Assets[j].Source
+		}
+		return m.Assets[i].Output < m.Assets[j].Output
+	})
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Input < m.Files[j].Input
+	})
+	sort.Slice(m.Sprites, func(i, j int) bool {
+		return m.Sprites[i].Name < m.Sprites[j].Name
+	})
+
+	bs, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// watch rewrites the manifest while others may be reading it
+	o, err := createOutput(b.Manifest, false)
+	if err != nil {
+		return err
+	}
+	if _, err := o.Write(append(bs, '\n')); err != nil {
+		o.Abort()
+		return err
+	}
+	return o.Commit()
+}
+
+// keepManifest adds the sprites and assets of the manifest at path,
+// that still exist, to m
+func keepManifest(m *Manifest, dir, path string) {
+	old, err := ReadManifest(path)
+	if err != nil {
+		return
+	}
+	exists := func(rel string) bool {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+		return err == nil
+	}
+
+	sprites := make(map[string]bool)
+	for _, s := range m.Sprites {
+		sprites[s.Name] = true
+	}
+	for _, s := range old.Sprites {
+		if !sprites[s.Name] && exists(s.Output) {
+			m.Sprites = append(m.Sprites, s)
+		}
+	}
+
+	assets := make(map[string]bool)
+	for _, a := range m.Assets {
+		assets[a.key()] = true
+	}
+	for _, a := range old.Assets {
+		if !assets[a.key()] && exists(a.Output) {
+			m.Assets = append(m.Assets, a)
+		}
+	}
+}
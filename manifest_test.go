@@ -0,0 +1,158 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuild_manifest(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	bdir := filepath.Join(tdir, "build")
+	path := filepath.Join(bdir, "manifest.json")
+	args := &BuildArgs{
+		BuildDir:  bdir,
+		Includes:  []string{"test"},
+		SourceMap: true,
+		Manifest:  path,
+	}
+	args.WithPaths([]string{"test/compass"})
+	err = NewBuild(args, NewPartialMap()).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := 1; len(m.Files) != e {
+		t.Fatalf("got: %d wanted: %d", len(m.Files), e)
+	}
+	f := m.Files[0]
+	if e := "top.css"; f.Output != e {
+		t.Errorf("got: %s wanted: %s", f.Output, e)
+	}
+	if e := "top.css.map"; f.SourceMap != e {
+		t.Errorf("got: %s wanted: %s", f.SourceMap, e)
+	}
+	if e := 3; len(f.Imports) != e {
+		t.Errorf("got: %d wanted: %d", len(f.Imports), e)
+	}
+
+	hash, size, err := hashFile(filepath.Join(bdir, "top.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Hash != hash || f.Size != size {
+		t.Errorf("got: %s %d wanted: %s %d", f.Hash, f.Size, hash, size)
+	}
+}
+
+func TestBuild_manifestStdout(t *testing.T) {
+	args := &BuildArgs{Manifest: "manifest.json"}
+	args.WithPaths([]string{"test/sass/file.scss"})
+	err := NewBuild(args, NewPartialMap()).Run()
+	if err != ErrManifestBuildDir {
+		t.Errorf("got: %v wanted: %s", err, ErrManifestBuildDir)
+	}
+}
+
+func TestBuild_manifestFilename(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_filename")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	path := filepath.Join(tdir, "manifest.json")
+	args := &BuildArgs{
+		BuildDir:  tdir,
+		CacheBust: "filename",
+		Manifest:  path,
+	}
+	args.WithPaths([]string{"test/sass/file.scss"})
+	err = NewBuild(args, NewPartialMap()).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := 1; len(m.Files) != e {
+		t.Fatalf("got: %d wanted: %d", len(m.Files), e)
+	}
+	f := m.Files[0]
+	if e := "file." + f.Hash[:8] + ".css"; f.Output != e {
+		t.Errorf("got: %s wanted: %s", f.Output, e)
+	}
+	if _, err := os.Stat(filepath.Join(tdir, "file.css")); !os.IsNotExist(err) {
+		t.Errorf("unhashed output was not removed: %v", err)
+	}
+}
+
+func TestShareManifests(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testsharemanifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	path := filepath.Join(tdir, "build", "manifest.json")
+	var args []*BuildArgs
+	for _, name := range []string{"web", "admin"} {
+		sdir := filepath.Join(tdir, name)
+		if err := os.MkdirAll(sdir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		err := ioutil.WriteFile(filepath.Join(sdir, name+".scss"),
+			[]byte("div { color: red; }"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gba := &BuildArgs{
+			BuildDir: filepath.Join(tdir, "build", name),
+			Manifest: path,
+		}
+		gba.WithPaths([]string{sdir})
+		args = append(args, gba)
+	}
+	ShareManifests(args...)
+
+	for _, gba := range args {
+		if err := NewBuild(gba, NewPartialMap()).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outputs []string
+	for _, f := range m.Files {
+		outputs = append(outputs, f.Output)
+	}
+	if e := []string{"admin/admin.css", "web/web.css"}; !reflect.DeepEqual(outputs, e) {
+		t.Errorf("got: %v wanted: %v", outputs, e)
+	}
+
+	// Building a target again replaces only its own files
+	if err := NewBuild(args[0], NewPartialMap()).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = ReadManifest(path); err != nil {
+		t.Fatal(err)
+	}
+	if e := 2; len(m.Files) != e {
+		t.Errorf("got: %d wanted: %d", len(m.Files), e)
+	}
+}
@@ -0,0 +1,106 @@
+package wellington
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// output is a CSS file, and optionally its source map, written to
+// temporary files beside their final location. They replace the
+// existing files when the compile succeeds, a failed compile leaves
+// the last good output in place.
+type output struct {
+	*os.File
+	path string
+	// smap is the temporary source map, empty without a source map
+	smap string
+}
+
+// createOutput starts writing the CSS file at path
+func createOutput(path string, sourceMap bool) (*output, error) {
+	dir, base := filepath.Split(path)
+	f, err := ioutil.TempFile(dir, "."+base+".")
+	if err != nil {
+		return nil, err
+	}
+	o := &output{File: f, path: path}
+	if sourceMap {
+		o.smap = f.Name() + ".map"
+	}
+	return o, nil
+}
+
+// Close closes the temporary file, the output is not moved into place
+// until Commit
+func (o *output) Close() error {
+	err := o.File.Close()
+	if pe, ok := err.(*os.PathError); ok && pe.Err == os.ErrClosed {
+		return nil
+	}
+	return err
+}
+
+// Commit moves the output over the existing files
+func (o *output) Commit() error {
+	if err := o.Close(); err != nil {
+		o.Abort()
+		return err
+	}
+	if len(o.smap) > 0 {
+		if err := o.rename(); err != nil {
+			o.Abort()
+			return err
+		}
+	}
+	if err := os.Chmod(o.Name(), 0644); err != nil {
+		o.Abort()
+		return err
+	}
+	return os.Rename(o.Name(), o.path)
+}
+
+// Abort removes the temporary files
+func (o *output) Abort() {
+	o.Close()
+	os.Remove(o.Name())
+	if len(o.smap) > 0 {
+		os.Remove(o.smap)
+	}
+}
+
+// rename replaces the temporary names libsass wrote into the CSS and
+// source map with the final ones, then moves the source map in place
+func (o *output) rename() error {
+	base := filepath.Base(o.path)
+	err := replaceIn(o.Name(), filepath.Base(o.smap), base+".map")
+	if err != nil {
+		return err
+	}
+	// libsass only writes the source map if there are mappings
+	if _, err := os.Stat(o.smap); os.IsNotExist(err) {
+		return nil
+	}
+	err = replaceIn(o.smap, filepath.Base(o.Name()), base)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(o.smap, 0644); err != nil {
+		return err
+	}
+	return os.Rename(o.smap, o.path+".map")
+}
+
+// replaceIn replaces old with new in the file at path
+func replaceIn(path, old, new string) error {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(bs, []byte(old)) {
+		return nil
+	}
+	bs = bytes.Replace(bs, []byte(old), []byte(new), -1)
+	return ioutil.WriteFile(path, bs, 0644)
+}
@@ -0,0 +1,67 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuild_atomic(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(sdir, "main.scss")
+	bdir := filepath.Join(tdir, "build")
+	build := func(contents string) error {
+		if err := ioutil.WriteFile(src, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args := &BuildArgs{BuildDir: bdir, SourceMap: true}
+		args.WithPaths([]string{sdir})
+		return NewBuild(args, NewPartialMap()).Run()
+	}
+
+	if err := build("div { color: red; }"); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(bdir, "main.css")
+	good, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := "\n/*# sourceMappingURL=main.css.map */"
+	if got := string(good[len(good)-len(e):]); got != e {
+		t.Errorf("got: %q wanted: %q", got, e)
+	}
+
+	if err := build("div {"); err == nil {
+		t.Fatal("no error reported")
+	}
+	bs, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != string(good) {
+		t.Errorf("output replaced by failed build got:\n%s\nwanted:\n%s", bs, good)
+	}
+
+	files, err := ioutil.ReadDir(bdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if len(names) != 2 {
+		t.Errorf("got: %v wanted: [main.css main.css.map]", names)
+	}
+}
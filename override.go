@@ -0,0 +1,186 @@
+package wellington
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	libsass "github.com/wellington/go-libsass"
+)
+
+// Override changes the options used to build the top level files
+// matching Match. Empty fields leave the option unchanged.
+type Override struct {
+	// Match is a glob pattern, see sourceFilter. Patterns containing a
+	// slash match the path relative to the directory searched.
+	Match     string `json:"match" yaml:"match"`
+	Style     string `json:"style" yaml:"style"`
+	Comments  *bool  `json:"comment" yaml:"comment"`
+	SourceMap *bool  `json:"source-map" yaml:"source-map"`
+	ImageDir  string `json:"dir" yaml:"dir"`
+	CacheBust string `json:"cachebust" yaml:"cachebust"`
+}
+
+// directivePrefix starts a comment overriding the options of a file,
+// ie. // wt: style=compressed, sourcemap=true
+const directivePrefix = "wt:"
+
+// apply sets the options of the override on b
+func (o *Override) apply(b *BuildArgs) {
+	if style, ok := libsass.Style[o.Style]; ok {
+		b.Style = style
+	}
+	if o.Comments != nil {
+		b.Comments = *o.Comments
+	}
+	if o.SourceMap != nil {
+		b.SourceMap = *o.SourceMap
+	}
+	if len(o.ImageDir) > 0 {
+		b.ImageDir = o.ImageDir
+	}
+	if len(o.CacheBust) > 0 {
+		b.CacheBust = o.CacheBust
+	}
+}
+
+// fileArgs returns the arguments used to build path. Overrides matching
+// path are applied in order followed by the directives at the top of
+// the file. b is returned when nothing is overridden.
+func (b *BuildArgs) fileArgs(path string) (*BuildArgs, error) {
+	o, err := readDirectives(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Overrides) == 0 && o == nil {
+		return b, nil
+	}
+
+	fa := *b
+	rel := filepath.ToSlash(filepath.Join(relative(b.paths, path),
+		filepath.Base(path)))
+	for i := range b.Overrides {
+		if matchPattern(b.Overrides[i].Match, rel) {
+			b.Overrides[i].apply(&fa)
+		}
+	}
+	if o != nil {
+		o.apply(&fa)
+	}
+	return &fa, nil
+}
+
+// readDirectives parses the wt: comments that start path, nil is
+// returned when there are none. Only // comments before the first line
+// of Sass are read, so long lines of minified Sass are not buffered.
+func readDirectives(path string) (*Override, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var o *Override
+	r := bufio.NewReader(f)
+	for {
+		line, err := readComment(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "//"))
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+		if o == nil {
+			o = &Override{}
+		}
+		err = o.parse(strings.TrimPrefix(line, directivePrefix),
+			filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	return o, nil
+}
+
+// readComment returns the next line of r. Lines longer than the buffer
+// of r are only read to the end when they are comments, the start of
+// any other line is returned.
+func readComment(r *bufio.Reader) (string, error) {
+	b, more, err := r.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	line := string(b)
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) > 0 && !strings.HasPrefix(trimmed, "//") {
+		return line, nil
+	}
+	for more {
+		b, more, err = r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line += string(b)
+	}
+	return line, nil
+}
+
+// parse reads comma separated key=value options into o. Relative image
+// directories are resolved against dir.
+func (o *Override) parse(s, dir string) error {
+	for _, opt := range strings.Split(s, ",") {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid wt option: %s", opt)
+		}
+		key, val := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "style":
+			if err := validStyle(val); err != nil {
+				return err
+			}
+			o.Style = val
+		case "comment", "comments":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %s", key, val)
+			}
+			o.Comments = &b
+		case "sourcemap", "source-map":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %s", key, val)
+			}
+			o.SourceMap = &b
+		case "dir":
+			if !filepath.IsAbs(val) {
+				val = filepath.Join(dir, val)
+			}
+			o.ImageDir = val
+		case "cachebust":
+			o.CacheBust = val
+		default:
+			return fmt.Errorf("unknown wt option: %s", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	libsass "github.com/wellington/go-libsass"
+)
+
+func TestReadDirectives(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testreaddirectives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	write := func(contents string) string {
+		path := filepath.Join(tdir, "file.scss")
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	o, err := readDirectives(write(`
+// Landing page
+// wt: style=compressed, sourcemap=true
+// wt: dir=img
+div { color: red; }
+// wt: comments=true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o == nil {
+		t.Fatal("no directives found")
+	}
+	if o.Style != "compressed" {
+		t.Errorf("got: %s wanted: compressed", o.Style)
+	}
+	if o.SourceMap == nil || !*o.SourceMap {
+		t.Errorf("sourcemap not set")
+	}
+	if e := filepath.Join(tdir, "img"); o.ImageDir != e {
+		t.Errorf("got: %s wanted: %s", o.ImageDir, e)
+	}
+	// Directives after the first line of Sass are ignored
+	if o.Comments != nil {
+		t.Errorf("comments set from the body of the file")
+	}
+
+	o, err = readDirectives(write("div { color: red; }\n"))
+	if err != nil || o != nil {
+		t.Errorf("got: %v, %v wanted no directives", o, err)
+	}
+
+	// Lines longer than the scanner limit, ie. minified Sass, are read
+	long := strings.Repeat("a", 128*1024)
+	o, err = readDirectives(write("// " + long + "\n// wt: style=compressed\n" +
+		"div { content: '" + long + "'; }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o == nil || o.Style != "compressed" {
+		t.Errorf("got: %v wanted: compressed", o)
+	}
+	o, err = readDirectives(write("div{content:'" + long + "'}"))
+	if err != nil || o != nil {
+		t.Errorf("got: %v, %v wanted no directives", o, err)
+	}
+
+	errs := map[string]string{
+		"// wt: style=fancy":    "invalid style: fancy",
+		"// wt: color=red":      "unknown wt option: color",
+		"// wt: sourcemap=yeah": "invalid sourcemap: yeah",
+		"// wt: compressed":     "invalid wt option: compressed",
+	}
+	for in, e := range errs {
+		_, err := readDirectives(write(in))
+		if err == nil || !strings.HasSuffix(err.Error(), e) {
+			t.Errorf("%s got: %v wanted: %s", in, err, e)
+		}
+	}
+}
+
+func TestBuild_overrides(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_overrides")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(filepath.Join(sdir, "pages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.scss":       "div {\n  color: red; }\n",
+		"b.scss":       "// wt: style=compressed, sourcemap=true\ndiv {\n  color: red; }\n",
+		"pages/c.scss": "div {\n  color: red; }\n",
+	}
+	for name, contents := range files {
+		err := ioutil.WriteFile(filepath.Join(sdir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	comments := true
+	bdir := filepath.Join(tdir, "build")
+	args := &BuildArgs{
+		BuildDir: bdir,
+		Style:    libsass.EXPANDED_STYLE,
+		Overrides: []Override{
+			{Match: "pages/*.scss", Comments: &comments},
+		},
+	}
+	args.WithPaths([]string{sdir})
+	b := NewBuild(args, NewPartialMap())
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		bs, err := ioutil.ReadFile(filepath.Join(bdir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bs)
+	}
+	if e := "div {\n  color: red;\n}\n"; read("a.css") != e {
+		t.Errorf("got: %q wanted: %q", read("a.css"), e)
+	}
+	if got := read("b.css"); !strings.HasPrefix(got, "div{color:red}") {
+		t.Errorf("got: %q wanted compressed", got)
+	}
+	if _, err := os.Stat(filepath.Join(bdir, "b.css.map")); err != nil {
+		t.Errorf("source map not written: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(bdir, "a.css.map")); !os.IsNotExist(err) {
+		t.Errorf("unexpected source map: %v", err)
+	}
+	if got := read(filepath.Join("pages", "c.css")); !strings.Contains(got, "/* line 1") {
+		t.Errorf("got: %q wanted line comments", got)
+	}
+
+	plan, err := b.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pf := range plan {
+		hasMap := len(pf.SourceMap) > 0
+		if e := filepath.Base(pf.Input) == "b.scss"; hasMap != e {
+			t.Errorf("%s got source map: %t wanted: %t", pf.Input, hasMap, e)
+		}
+	}
+}
@@ -56,18 +56,20 @@ func relative(paths []string, file string) string {
 	return ""
 }
 
-func pathsToFiles(paths []string, recurse bool) []string {
+// pathsToFiles finds the Sass files to build in paths, skipping those
+// rejected by the include and exclude patterns, see sourceFilter
+func pathsToFiles(paths []string, recurse bool, include, exclude []string) []string {
 	var rollup []string
 	for _, path := range paths {
 		if recurse {
-			paths, err := recursePath(path)
+			paths, err := recursePath(path, include, exclude)
 			if err != nil {
 				fmt.Println(err)
 				continue
 			}
 			rollup = append(rollup, paths...)
 		} else {
-			rollup = append(rollup, resolvePath(path)...)
+			rollup = append(rollup, resolvePath(path, include, exclude)...)
 		}
 	}
 	return rollup
@@ -91,12 +93,24 @@ func isImportable(name string) bool {
 
 // recursePath takes an input path and locates all non-partial scss/sass
 // files in it
-func recursePath(path string) ([]string, error) {
+func recursePath(root string, include, exclude []string) ([]string, error) {
+	filter, err := newSourceFilter(root, include, exclude)
+	if err != nil {
+		return nil, err
+	}
 	var paths []string
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if info == nil {
 			return fmt.Errorf("invalid file found: %s", path)
 		}
+		// Filters do not apply to paths passed explicitly
+		if rel, err := filepath.Rel(root, path); err == nil && rel != "." &&
+			filter.skip(filepath.ToSlash(rel), info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if !info.IsDir() && isImportable(info.Name()) {
 			paths = append(paths, path)
 		}
@@ -106,14 +120,23 @@ func recursePath(path string) ([]string, error) {
 }
 
 //
-func resolvePath(path string) []string {
+func resolvePath(root string, include, exclude []string) []string {
+	filter, err := newSourceFilter(root, include, exclude)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
 	var paths []string
 	for _, r := range resolveExts {
-		some, err := filepath.Glob(filepath.Join(path, "*"+r))
+		some, err := filepath.Glob(filepath.Join(root, "*"+r))
 		if err != nil {
 			continue
 		}
-		paths = append(paths, some...)
+		for _, path := range some {
+			if !filter.skip(filepath.Base(path), false) {
+				paths = append(paths, path)
+			}
+		}
 	}
 
 	return paths
@@ -7,14 +7,14 @@ import (
 
 func TestPath_recurse(t *testing.T) {
 
-	paths := pathsToFiles([]string{"test/includes"}, true)
+	paths := pathsToFiles([]string{"test/includes"}, true, nil, nil)
 	if len(paths) != 1 {
 		t.Fatal("wrong number of returned paths")
 	}
 
 	// This is going to be a really annoying test, should setup a special
 	// directory to test this.
-	paths = pathsToFiles([]string{"test"}, true)
+	paths = pathsToFiles([]string{"test"}, true, nil, nil)
 	if e := 20; len(paths) != e {
 		t.Errorf("got: %d wanted: %d", len(paths), e)
 	}
@@ -22,14 +22,14 @@ func TestPath_recurse(t *testing.T) {
 
 func TestPath_files(t *testing.T) {
 
-	paths := pathsToFiles([]string{"test/includes"}, false)
+	paths := pathsToFiles([]string{"test/includes"}, false, nil, nil)
 	if len(paths) != 1 {
 		t.Fatal("wrong number of returned paths")
 	}
 
 	// This is going to be a really annoying test, should setup a special
 	// directory to test this.
-	paths = pathsToFiles([]string{"test"}, false)
+	paths = pathsToFiles([]string{"test"}, false, nil, nil)
 	if e := 2; len(paths) != e {
 		t.Errorf("got: %d wanted: %d", len(paths), e)
 	}
@@ -1,6 +1,10 @@
 package payload
 
 import (
+	"path/filepath"
+	"sync"
+	"time"
+
 	"github.com/wellington/spritewell"
 	"golang.org/x/net/context"
 )
@@ -13,6 +17,10 @@ const (
 	_             = iota
 	spriteKey key = iota
 	imageKey  key = iota
+	waitKey   key = iota
+	hashedKey key = iota
+	warnKey   key = iota
+	assetKey  key = iota
 )
 
 // New returns a Context with an attached payload for Sprites and Images
@@ -21,10 +29,54 @@ func New() context.Context {
 		spriteKey, spritewell.NewImageMap())
 	ctx = context.WithValue(ctx,
 		imageKey, spritewell.NewImageMap())
+	ctx = context.WithValue(ctx,
+		waitKey, &waited{m: make(map[*spritewell.Sprite]*spriteWait)})
+	ctx = context.WithValue(ctx,
+		hashedKey, &HashedMap{M: make(map[string]map[string]string)})
 
 	return ctx
 }
 
+// waited records the sprites that have been flushed to disk, a sprite
+// only signals this once.
+type waited struct {
+	sync.Mutex
+	m map[*spritewell.Sprite]*spriteWait
+}
+
+// spriteWait is the outcome of writing a sprite to disk
+type spriteWait struct {
+	once  sync.Once
+	start time.Time
+	dur   time.Duration
+	err   error
+	// done is called with the result of writing the sprite
+	done func(error) error
+}
+
+func (w *waited) get(sprite *spritewell.Sprite) *spriteWait {
+	w.Lock()
+	defer w.Unlock()
+	sw, ok := w.m[sprite]
+	if !ok {
+		sw = &spriteWait{start: time.Now()}
+		w.m[sprite] = sw
+	}
+	return sw
+}
+
+func (w *waited) wait(sprite *spritewell.Sprite) error {
+	sw := w.get(sprite)
+	sw.once.Do(func() {
+		sw.err = sprite.Wait()
+		if sw.done != nil {
+			sw.err = sw.done(sw.err)
+		}
+		sw.dur = time.Since(sw.start)
+	})
+	return sw.err
+}
+
 // Payloader describes the way to communicate with underlying datastore
 // a payload describes.
 type Payloader interface {
@@ -42,3 +94,192 @@ func Sprite(ctx context.Context) Payloader {
 func Image(ctx context.Context) Payloader {
 	return ctx.Value(imageKey).(Payloader)
 }
+
+// WaitSprite blocks until sprite has been written to disk. Unlike
+// Sprite.Wait, it may be called any number of times.
+func WaitSprite(ctx context.Context, sprite *spritewell.Sprite) error {
+	w, ok := ctx.Value(waitKey).(*waited)
+	if !ok {
+		return sprite.Wait()
+	}
+	return w.wait(sprite)
+}
+
+// Track starts timing sprite, which must have just been exported. The
+// time taken to write it to disk is available from Duration. If done is
+// not nil, it is called with the result of writing the sprite before
+// any wait on the sprite returns, its result replaces the original.
+func Track(ctx context.Context, sprite *spritewell.Sprite, done func(error) error) {
+	w, ok := ctx.Value(waitKey).(*waited)
+	if !ok {
+		if done != nil {
+			go done(sprite.Wait())
+		}
+		return
+	}
+	w.get(sprite).done = done
+	go w.wait(sprite)
+}
+
+// Duration blocks until sprite is written to disk and returns how long
+// it took to generate after Track. If the sprite was not tracked, the
+// time from the first wait is returned.
+func Duration(ctx context.Context, sprite *spritewell.Sprite) time.Duration {
+	w, ok := ctx.Value(waitKey).(*waited)
+	if !ok {
+		return 0
+	}
+	w.wait(sprite)
+	return w.get(sprite).dur
+}
+
+// HashedMap records copies of assets named after their content, keyed
+// by the path of the original asset and the directory of the copy.
+// Outputs in different directories each have their own copy.
+type HashedMap struct {
+	sync.RWMutex
+	M map[string]map[string]string
+}
+
+// Get returns the content hashed copy of path written to dir
+func (h *HashedMap) Get(path, dir string) (string, bool) {
+	h.RLock()
+	defer h.RUnlock()
+	hashed, ok := h.M[path][dir]
+	return hashed, ok
+}
+
+// Set records hashed as the content hashed copy of path in the
+// directory of hashed, the copy recorded there before is returned
+func (h *HashedMap) Set(path, hashed string) string {
+	h.Lock()
+	defer h.Unlock()
+	dir := filepath.Dir(hashed)
+	if h.M[path] == nil {
+		h.M[path] = make(map[string]string)
+	}
+	old := h.M[path][dir]
+	h.M[path][dir] = hashed
+	return old
+}
+
+// ForEach calls fn for every copy of the recorded assets
+func (h *HashedMap) ForEach(fn func(path, hashed string)) {
+	h.RLock()
+	defer h.RUnlock()
+	for k, copies := range h.M {
+		for _, v := range copies {
+			fn(k, v)
+		}
+	}
+}
+
+// Hashed is a convenience to return the content hashed assets, nil is
+// returned if the context was not created by New.
+func Hashed(ctx context.Context) *HashedMap {
+	h, _ := ctx.Value(hashedKey).(*HashedMap)
+	return h
+}
+
+// WarningList collects the @warn messages of a compile
+type WarningList struct {
+	sync.Mutex
+	L []string
+}
+
+// Add records a warning
+func (l *WarningList) Add(msg string) {
+	l.Lock()
+	defer l.Unlock()
+	l.L = append(l.L, msg)
+}
+
+// List returns the warnings in the order they were added
+func (l *WarningList) List() []string {
+	l.Lock()
+	defer l.Unlock()
+	return append([]string{}, l.L...)
+}
+
+// WithWarnings returns a copy of ctx that collects the warnings of a
+// single compile. Sprites and images are still shared with ctx.
+func WithWarnings(ctx context.Context) context.Context {
+	return context.WithValue(ctx, warnKey, &WarningList{})
+}
+
+// Warnings is a convenience to return the warnings collected by the
+// context, nil is returned if it was not created by WithWarnings.
+func Warnings(ctx context.Context) *WarningList {
+	l, _ := ctx.Value(warnKey).(*WarningList)
+	return l
+}
+
+// AssetList collects the images, fonts and sprite globs used by a
+// compile
+type AssetList struct {
+	sync.Mutex
+	L []string
+}
+
+// Add records the absolute path, or glob, of an asset
+func (l *AssetList) Add(path string) {
+	l.Lock()
+	defer l.Unlock()
+	for _, p := range l.L {
+		if p == path {
+			return
+		}
+	}
+	l.L = append(l.L, path)
+}
+
+// List returns the assets in the order they were added
+func (l *AssetList) List() []string {
+	l.Lock()
+	defer l.Unlock()
+	return append([]string{}, l.L...)
+}
+
+// WithAssets returns a copy of ctx that collects the assets of a single
+// compile. Sprites and images are still shared with ctx.
+func WithAssets(ctx context.Context) context.Context {
+	return context.WithValue(ctx, assetKey, &AssetList{})
+}
+
+// Assets is a convenience to return the assets collected by the
+// context, nil is returned if it was not created by WithAssets.
+func Assets(ctx context.Context) *AssetList {
+	l, _ := ctx.Value(assetKey).(*AssetList)
+	return l
+}
+
+// ForgetImages drops the images decoded for image-width and
+// image-height, they are read again the next time they are used
+func ForgetImages(ctx context.Context) {
+	m, ok := ctx.Value(imageKey).(*spritewell.SafeImageMap)
+	if !ok {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.M = make(map[string]*spritewell.Sprite)
+}
+
+// Wait blocks until every sprite in the payload has been written to
+// disk and returns the first error encountered. It is safe to call Wait
+// more than once on the same payload.
+func Wait(ctx context.Context) error {
+	var sprites []*spritewell.Sprite
+	Sprite(ctx).ForEach(func(_ string, sprite *spritewell.Sprite) {
+		sprites = append(sprites, sprite)
+	})
+
+	var first error
+	for _, sprite := range sprites {
+		err := WaitSprite(ctx, sprite)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
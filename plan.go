@@ -0,0 +1,84 @@
+package wellington
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// PlanFile is a Sass file a Build would compile and where its output
+// would be written
+type PlanFile struct {
+	Input string `json:"input"`
+	// Output is empty when the CSS is written to stdout
+	Output    string `json:"output,omitempty"`
+	SourceMap string `json:"sourcemap,omitempty"`
+	// RTL is the mirrored copy of Output, see BuildArgs.RTL
+	RTL string `json:"rtl,omitempty"`
+}
+
+// Plan lists the files a Build would compile, in build order
+type Plan []PlanFile
+
+// Plan finds the files Run would compile and resolves their outputs,
+// including Overrides, without compiling or writing anything. With
+// CacheBust "filename" the output is the name before the content hash
+// is added.
+func (b *Build) Plan() (Plan, error) {
+	files, err := b.findFiles()
+	if err != nil {
+		return nil, err
+	}
+	plan := make(Plan, 0, len(files))
+	for _, file := range files {
+		pf := PlanFile{Input: file}
+		fa, err := b.bArgs.fileArgs(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(fa.BuildDir) > 0 {
+			pf.Output = fa.outPath(file)
+			if fa.SourceMap {
+				pf.SourceMap = pf.Output + ".map"
+			}
+			if fa.RTL {
+				pf.RTL = rtlPath(pf.Output)
+			}
+		}
+		plan = append(plan, pf)
+	}
+	return plan, nil
+}
+
+// WriteText writes a line for each file, input -> output
+func (p Plan) WriteText(w io.Writer) error {
+	for _, pf := range p {
+		out := pf.Output
+		if len(out) == 0 {
+			out = "stdout"
+		}
+		if len(pf.SourceMap) > 0 {
+			out += " (" + pf.SourceMap + ")"
+		}
+		if len(pf.RTL) > 0 {
+			out += ", " + pf.RTL
+		}
+		if _, err := fmt.Fprintf(w, "%s -> %s\n", pf.Input, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the plan as indented JSON
+func (p Plan) WriteJSON(w io.Writer) error {
+	if p == nil {
+		p = Plan{}
+	}
+	bs, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(bs, '\n'))
+	return err
+}
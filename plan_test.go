@@ -0,0 +1,92 @@
+package wellington
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuild_Plan(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	bdir := filepath.Join(tdir, "build")
+	args := &BuildArgs{BuildDir: bdir, SourceMap: true}
+	args.WithPaths([]string{filepath.Join("test", "proj")})
+	b := NewBuild(args, NewPartialMap())
+	plan, err := b.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Plan{
+		{
+			Input:     filepath.Join("test", "proj", "main.sass"),
+			Output:    filepath.Join(bdir, "main.css"),
+			SourceMap: filepath.Join(bdir, "main.css.map"),
+		},
+		{
+			Input:     filepath.Join("test", "proj", "subdir", "second.sass"),
+			Output:    filepath.Join(bdir, "subdir", "second.css"),
+			SourceMap: filepath.Join(bdir, "subdir", "second.css.map"),
+		},
+	}
+	if len(plan) != len(e) {
+		t.Fatalf("got: %v wanted: %v", plan, e)
+	}
+	for i := range e {
+		if plan[i] != e[i] {
+			t.Errorf("got: %v wanted: %v", plan[i], e[i])
+		}
+	}
+
+	// Nothing is written
+	if _, err := os.Stat(bdir); !os.IsNotExist(err) {
+		t.Errorf("build directory was created: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := plan.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	line := e[0].Input + " -> " + e[0].Output + " (" + e[0].SourceMap + ")\n"
+	if got := buf.String(); got[:len(line)] != line {
+		t.Errorf("got: %q wanted prefix: %q", got, line)
+	}
+
+	buf.Reset()
+	if err := plan.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Plan
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[1] != e[1] {
+		t.Errorf("got: %v wanted: %v", decoded, e)
+	}
+}
+
+func TestBuild_Plan_stdout(t *testing.T) {
+	args := &BuildArgs{}
+	args.WithPaths([]string{filepath.Join("test", "proj")})
+	plan, err := NewBuild(args, NewPartialMap()).Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 {
+		t.Fatalf("got: %d wanted: 2", len(plan))
+	}
+	var buf bytes.Buffer
+	plan.WriteText(&buf)
+	e := filepath.Join("test", "proj", "main.sass") + " -> stdout\n"
+	if got := buf.String(); got[:len(e)] != e {
+		t.Errorf("got: %q wanted prefix: %q", got, e)
+	}
+}
@@ -0,0 +1,149 @@
+package wellington
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	libsass "github.com/wellington/go-libsass"
+)
+
+// Stylesheet is the output of a compile passed through each Processor
+type Stylesheet struct {
+	// Input is the Sass file compiled, it is empty when reading from
+	// stdin or serving HTTP
+	Input string
+	CSS   []byte
+	// SourceMap is empty when no source map is written
+	SourceMap []byte
+	// Args are the options used to compile Input
+	Args *BuildArgs
+}
+
+// Processor transforms compiled CSS before it is written. Processors
+// moving CSS around are responsible for updating the SourceMap.
+type Processor interface {
+	Process(s *Stylesheet) error
+}
+
+// ProcessorFunc adapts a function to a Processor
+type ProcessorFunc func(s *Stylesheet) error
+
+// Process calls f(s)
+func (f ProcessorFunc) Process(s *Stylesheet) error {
+	return f(s)
+}
+
+var processors = struct {
+	sync.RWMutex
+	m map[string]Processor
+}{m: make(map[string]Processor)}
+
+// RegisterProcessor makes p available by name to BuildArgs.Processors.
+// Like libsass.RegisterSassFunc it is meant to be called from init,
+// registering a name again replaces the Processor.
+func RegisterProcessor(name string, p Processor) {
+	processors.Lock()
+	processors.m[name] = p
+	processors.Unlock()
+}
+
+// ProcessorNames returns the names of every registered Processor
+func ProcessorNames() []string {
+	processors.RLock()
+	defer processors.RUnlock()
+	names := make([]string, 0, len(processors.m))
+	for name := range processors.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// processor returns the Processor registered as name
+func processor(name string) (Processor, bool) {
+	processors.RLock()
+	defer processors.RUnlock()
+	p, ok := processors.m[name]
+	return p, ok
+}
+
+// checkProcessors verifies every name is registered
+func checkProcessors(names []string) error {
+	for _, name := range names {
+		if _, ok := processor(name); !ok {
+			return fmt.Errorf("unknown processor: %s, available: %v",
+				name, ProcessorNames())
+		}
+	}
+	return nil
+}
+
+// process runs the Processors of b on s in order
+func (b *BuildArgs) process(s *Stylesheet) error {
+	for _, name := range b.Processors {
+		p, ok := processor(name)
+		if !ok {
+			return checkProcessors([]string{name})
+		}
+		if err := p.Process(s); err != nil {
+			return fmt.Errorf("processor %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// process passes the CSS and source map written by libsass through the
+// Processors of gba, replacing the temporary files
+func (o *output) process(input string, gba *BuildArgs) error {
+	if err := o.Close(); err != nil {
+		return err
+	}
+	css, err := ioutil.ReadFile(o.Name())
+	if err != nil {
+		return err
+	}
+	s := &Stylesheet{Input: input, CSS: css, Args: gba}
+	if len(o.smap) > 0 {
+		s.SourceMap, err = ioutil.ReadFile(o.smap)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := gba.process(s); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(o.Name(), s.CSS, 0644); err != nil {
+		return err
+	}
+	if len(s.SourceMap) == 0 {
+		return nil
+	}
+	return ioutil.WriteFile(o.smap, s.SourceMap, 0644)
+}
+
+// processCompiler buffers the output of a compiler, passing it through
+// the Processors of gba before writing it to dst
+type processCompiler struct {
+	libsass.Compiler
+	dst io.Writer
+	buf *bytes.Buffer
+	gba *BuildArgs
+}
+
+// Run compiles and processes the CSS, nothing is written on failure
+func (c *processCompiler) Run() error {
+	if err := c.Compiler.Run(); err != nil {
+		return err
+	}
+	s := &Stylesheet{CSS: c.buf.Bytes(), Args: c.gba}
+	if err := c.gba.process(s); err != nil {
+		return err
+	}
+	_, err := c.dst.Write(s.CSS)
+	return err
+}
@@ -0,0 +1,105 @@
+package wellington
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterProcessor("test-blue", ProcessorFunc(func(s *Stylesheet) error {
+		s.CSS = bytes.Replace(s.CSS, []byte("red"), []byte("blue"), -1)
+		if len(s.SourceMap) > 0 {
+			s.SourceMap = append(s.SourceMap, '\n')
+		}
+		return nil
+	}))
+	RegisterProcessor("test-fail", ProcessorFunc(func(s *Stylesheet) error {
+		return errors.New("no")
+	}))
+}
+
+func TestBuild_processors(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_processors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(sdir, "a.scss"),
+		[]byte("div {\n  color: red; }\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bdir := filepath.Join(tdir, "build")
+	args := &BuildArgs{
+		BuildDir:   bdir,
+		SourceMap:  true,
+		Processors: []string{"test-blue"},
+	}
+	args.WithPaths([]string{sdir})
+	if err := NewBuild(args, NewPartialMap()).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := ioutil.ReadFile(filepath.Join(bdir, "a.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "color: blue;") {
+		t.Errorf("got: %q wanted blue", bs)
+	}
+	// The temporary name of the source map is still replaced
+	if e := "sourceMappingURL=a.css.map"; !strings.Contains(string(bs), e) {
+		t.Errorf("got: %q wanted: %s", bs, e)
+	}
+	bs, err = ioutil.ReadFile(filepath.Join(bdir, "a.css.map"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(bs, []byte("\n")) {
+		t.Errorf("source map was not processed: %q", bs)
+	}
+
+	// A failing processor leaves the last output in place
+	args.Processors = []string{"test-fail"}
+	err = NewBuild(args, NewPartialMap()).Run()
+	if e := "processor test-fail: no"; err == nil || !strings.Contains(err.Error(), e) {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+	if _, err := os.Stat(filepath.Join(bdir, "a.css")); err != nil {
+		t.Errorf("last output removed: %s", err)
+	}
+
+	args.Processors = []string{"test-missing"}
+	err = NewBuild(args, NewPartialMap()).Run()
+	if e := "unknown processor: test-missing"; err == nil || !strings.HasPrefix(err.Error(), e) {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+}
+
+func TestFromBuildArgs_processors(t *testing.T) {
+	var out bytes.Buffer
+	in := bytes.NewBufferString("div { color: red; }")
+	comp, err := FromBuildArgs(&out, "", in, &BuildArgs{
+		Processors: []string{"test-blue"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := comp.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if e := "div {\n  color: blue; }\n"; out.String() != e {
+		t.Errorf("got: %q wanted: %q", out.String(), e)
+	}
+}
@@ -0,0 +1,413 @@
+package processors
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	wt "github.com/wellington/wellington"
+)
+
+func init() {
+	wt.RegisterProcessor("autoprefixer", wt.ProcessorFunc(Autoprefix))
+}
+
+// DefaultBrowsers is the query used when BuildArgs.Browsers is empty
+const DefaultBrowsers = "defaults"
+
+// Autoprefix adds the vendor prefixes the browsers selected by
+// BuildArgs.Browsers need and removes those none of them need. Only
+// prefixes listed in the support table, see browsers.json, are
+// removed.
+func Autoprefix(s *wt.Stylesheet) error {
+	query := DefaultBrowsers
+	if s.Args != nil && len(s.Args.Browsers) > 0 {
+		query = s.Args.Browsers
+	}
+	p, err := newPrefixer(query)
+	if err != nil {
+		return err
+	}
+
+	css := string(s.CSS)
+	edits := p.block(css, parseCSS(css), "")
+	if len(edits) == 0 {
+		return nil
+	}
+	smap, err := remap(s.SourceMap, css, edits)
+	if err != nil {
+		return err
+	}
+	s.CSS = []byte(applyEdits(css, edits))
+	s.SourceMap = smap
+	return nil
+}
+
+// prefixer adds and removes prefixes for a selection of browsers
+type prefixer struct {
+	t   *table
+	sel selection
+
+	mu sync.Mutex
+	// needed caches the prefixes each feature needs
+	needed map[*feature][]string
+}
+
+var prefixers = struct {
+	sync.Mutex
+	m map[string]*prefixer
+}{m: make(map[string]*prefixer)}
+
+// newPrefixer returns the prefixer for a browser query, prefixers are
+// reused across stylesheets
+func newPrefixer(query string) (*prefixer, error) {
+	prefixers.Lock()
+	defer prefixers.Unlock()
+	if p, ok := prefixers.m[query]; ok {
+		return p, nil
+	}
+	sel, err := browsers.query(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &prefixer{
+		t:      browsers,
+		sel:    sel,
+		needed: make(map[*feature][]string),
+	}
+	prefixers.m[query] = p
+	return p, nil
+}
+
+// prefixes returns the prefixes f needs, limited to only when set
+func (p *prefixer) prefixes(f *feature, only string) []string {
+	p.mu.Lock()
+	needed, ok := p.needed[f]
+	if !ok {
+		needed = p.t.prefixes(f, p.sel)
+		p.needed[f] = needed
+	}
+	p.mu.Unlock()
+	if len(only) == 0 {
+		return needed
+	}
+	if needs(needed, only) {
+		return []string{only}
+	}
+	return nil
+}
+
+func needs(prefixes []string, prefix string) bool {
+	for _, p := range prefixes {
+		if p == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// block returns the edits for nodes, only limits the prefixes added to
+// one ie. inside @-webkit-keyframes
+func (p *prefixer) block(css string, nodes []*node, only string) []edit {
+	var edits []edit
+	for _, n := range nodes {
+		switch n.kind {
+		case declNode:
+			edits = append(edits, p.decl(css, n, nodes, only)...)
+		case ruleNode:
+			edits = append(edits, p.rule(css, n, nodes, only)...)
+		case atNode:
+			edits = append(edits, p.atRule(css, n, nodes, only)...)
+		}
+	}
+	return edits
+}
+
+// removeNode removes n and the whitespace before it
+func removeNode(n *node) edit {
+	return remove(n.sep, n.end)
+}
+
+// obsolete finds the feature of a prefixed property, value or function
+// in a declaration. It reports whether the prefix is known and no
+// longer needed.
+func (p *prefixer) obsolete(n *node) bool {
+	if prefix, _ := splitPrefix(n.head); len(prefix) > 0 {
+		for _, f := range p.t.Properties {
+			if f.prefixed(prefix) == n.head {
+				return !needs(p.prefixes(f, ""), prefix)
+			}
+		}
+		return false
+	}
+
+	value := n.plainValue()
+	for _, f := range p.t.Values {
+		for _, prefix := range prefixOrder {
+			if f.appliesTo(n.head) && f.prefixed(prefix) == value {
+				return !needs(p.prefixes(f, ""), prefix)
+			}
+		}
+	}
+	for _, f := range p.t.Functions {
+		if !f.appliesTo(n.head) {
+			continue
+		}
+		for _, prefix := range prefixOrder {
+			if hasFunction(value, f.prefixed(prefix)) {
+				return !needs(p.prefixes(f, ""), prefix)
+			}
+		}
+	}
+	return false
+}
+
+// decl adds prefixed copies of a declaration before it, or removes it
+// when its prefix is obsolete
+func (p *prefixer) decl(css string, n *node, siblings []*node, only string) []edit {
+	if p.obsolete(n) {
+		return []edit{removeNode(n)}
+	}
+	if prefix, _ := splitPrefix(n.head); len(prefix) > 0 {
+		return nil
+	}
+
+	var copies []string
+	for _, prefix := range prefixOrder {
+		if len(only) > 0 && prefix != only {
+			continue
+		}
+		name, value := n.head, n.value
+		if f, ok := p.t.Properties[n.head]; ok && needs(p.prefixes(f, only), prefix) {
+			name = f.prefixed(prefix)
+			value = p.transition(name, value, prefix, only)
+		}
+		if f, ok := p.t.Values[n.plainValue()]; ok && f.appliesTo(n.head) &&
+			needs(p.prefixes(f, only), prefix) {
+			value = f.prefixed(prefix) + value[len(n.plainValue()):]
+		}
+		for _, f := range p.t.Functions {
+			if f.appliesTo(n.head) && hasFunction(value, f.name) &&
+				needs(p.prefixes(f, only), prefix) {
+				value = prefixFunction(value, f, prefix)
+			}
+		}
+		if name == n.head && value == n.value {
+			continue
+		}
+		if hasDecl(siblings, name, value) || name != n.head && hasDecl(siblings, name, "") {
+			continue
+		}
+		copies = append(copies, name+css[n.headEnd:n.valueStart]+value+";")
+	}
+	if len(copies) == 0 {
+		return nil
+	}
+	sep := indent(css, n)
+	return []edit{insert(n.start, strings.Join(copies, sep)+sep)}
+}
+
+// transition prefixes the properties named in the value of a prefixed
+// transition ie. -webkit-transition: -webkit-transform 1s
+func (p *prefixer) transition(name, value, prefix, only string) string {
+	if name != "-"+prefix+"-transition" &&
+		name != "-"+prefix+"-transition-property" {
+		return value
+	}
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		f, ok := p.t.Properties[fields[0]]
+		if !ok || !needs(p.prefixes(f, only), prefix) {
+			continue
+		}
+		parts[i] = strings.Replace(part, fields[0], f.prefixed(prefix), 1)
+	}
+	return strings.Join(parts, ",")
+}
+
+// hasDecl reports whether nodes contain a declaration of prop, with
+// value unless it is empty
+func hasDecl(nodes []*node, prop, value string) bool {
+	for _, n := range nodes {
+		if n.kind == declNode && n.head == prop &&
+			(len(value) == 0 || n.value == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRule reports whether nodes contain a rule or at-rule with head
+func hasRule(nodes []*node, head string) bool {
+	for _, n := range nodes {
+		if n.kind != declNode && n.head == head {
+			return true
+		}
+	}
+	return false
+}
+
+// rule adds copies of a rule using prefixed selectors, or removes it
+// when a prefixed selector is obsolete
+func (p *prefixer) rule(css string, n *node, siblings []*node, only string) []edit {
+	for _, f := range p.t.Selectors {
+		for prefix, name := range f.Names {
+			if strings.Contains(n.head, name) &&
+				!needs(p.prefixes(f, ""), prefix) {
+				return []edit{removeNode(n)}
+			}
+		}
+	}
+
+	var edits []edit
+	body := p.block(css, n.children, only)
+	for _, prefix := range prefixOrder {
+		if len(only) > 0 && prefix != only {
+			continue
+		}
+		head := n.head
+		for _, f := range p.t.Selectors {
+			name, ok := f.Names[prefix]
+			if ok && strings.Contains(head, f.name) &&
+				needs(p.prefixes(f, only), prefix) {
+				head = strings.Replace(head, f.name, name, -1)
+			}
+		}
+		if head == n.head || hasRule(siblings, head) {
+			continue
+		}
+		text := head + applyEdits(css[n.headEnd:n.end], shift(body, n.headEnd))
+		edits = append(edits, insert(n.start, text+indent(css, n)))
+	}
+	return append(edits, body...)
+}
+
+// atRule adds prefixed copies of @keyframes, or removes them when
+// obsolete, and processes the rules inside other at-rules
+func (p *prefixer) atRule(css string, n *node, siblings []*node, only string) []edit {
+	if n.body < 0 {
+		return nil
+	}
+	prefix, name := splitPrefix(n.name)
+	f, ok := p.t.AtRules[name]
+	if !ok {
+		return p.block(css, n.children, only)
+	}
+	if len(prefix) > 0 {
+		if !needs(p.prefixes(f, ""), prefix) {
+			return []edit{removeNode(n)}
+		}
+		return p.block(css, n.children, prefix)
+	}
+
+	var edits []edit
+	prelude := css[n.nameEnd:n.headEnd]
+	for _, prefix := range p.prefixes(f, only) {
+		head := "@" + f.prefixed(prefix) + prelude
+		if hasRule(siblings, head) {
+			continue
+		}
+		body := p.block(css, n.children, prefix)
+		text := "@" + f.prefixed(prefix) +
+			applyEdits(css[n.nameEnd:n.end], shift(body, n.nameEnd))
+		edits = append(edits, insert(n.start, text+indent(css, n)))
+	}
+	return append(edits, p.block(css, n.children, only)...)
+}
+
+// hasFunction reports whether value calls the function name
+func hasFunction(value, name string) bool {
+	return functionIndex(value, name, 0) >= 0
+}
+
+// functionIndex finds a call of name in value from start
+func functionIndex(value, name string, start int) int {
+	for start < len(value) {
+		i := strings.Index(value[start:], name+"(")
+		if i < 0 {
+			return -1
+		}
+		i += start
+		if i == 0 || !isNameChar(value[i-1]) {
+			return i
+		}
+		start = i + 1
+	}
+	return -1
+}
+
+// prefixFunction prefixes every call of the function f in value,
+// gradients are converted to the syntax prefixed gradients used
+func prefixFunction(value string, f *feature, prefix string) string {
+	name := f.prefixed(prefix)
+	var out strings.Builder
+	last := 0
+	for {
+		i := functionIndex(value, f.name, last)
+		if i < 0 {
+			break
+		}
+		out.WriteString(value[last:i])
+		out.WriteString(name)
+		last = i + len(f.name)
+		if !strings.HasSuffix(f.name, "gradient") {
+			continue
+		}
+		// the arguments up to the first comma
+		end := last + 1
+		for end < len(value) && value[end] != ',' && value[end] != ')' {
+			end++
+		}
+		out.WriteString("(")
+		out.WriteString(oldGradient(f.name, value[last+1:end]))
+		last = end
+	}
+	out.WriteString(value[last:])
+	return out.String()
+}
+
+var opposite = map[string]string{
+	"top": "bottom", "bottom": "top", "left": "right", "right": "left",
+}
+
+// oldGradient converts the first argument of a gradient to the syntax
+// used by prefixed gradients. Directions point the other way and
+// angles start from the east, counter clockwise.
+func oldGradient(name, arg string) string {
+	trimmed := strings.TrimSpace(arg)
+	if strings.Contains(name, "radial") {
+		// circle at center is written center, circle
+		if i := strings.Index(trimmed, " at "); i >= 0 {
+			return trimmed[i+4:] + ", " + trimmed[:i]
+		}
+		return arg
+	}
+	if strings.HasPrefix(trimmed, "to ") {
+		sides := strings.Fields(trimmed[3:])
+		for i, side := range sides {
+			if o, ok := opposite[side]; ok {
+				sides[i] = o
+			}
+		}
+		return strings.Join(sides, " ")
+	}
+	if strings.HasSuffix(trimmed, "deg") {
+		deg, err := strconv.ParseFloat(strings.TrimSuffix(trimmed, "deg"), 64)
+		if err != nil {
+			return arg
+		}
+		old := 90 - deg
+		for old < 0 {
+			old += 360
+		}
+		for old >= 360 {
+			old -= 360
+		}
+		return strconv.FormatFloat(old, 'f', -1, 64) + "deg"
+	}
+	return arg
+}
@@ -0,0 +1,143 @@
+package processors
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	wt "github.com/wellington/wellington"
+)
+
+func TestAutoprefix(t *testing.T) {
+	tests := []struct {
+		browsers string
+		in, out  string
+	}{
+		{
+			"ie 10, chrome 25",
+			"div {\n  display: flex;\n  user-select: none;\n}\n",
+			"div {\n  display: -webkit-flex;\n  display: -ms-flexbox;\n" +
+				"  display: flex;\n  -webkit-user-select: none;\n" +
+				"  -ms-user-select: none;\n  user-select: none;\n}\n",
+		},
+		{
+			"chrome 25",
+			"a {\n  transition: transform 1s;\n}\n",
+			"a {\n  -webkit-transition: -webkit-transform 1s;\n" +
+				"  transition: transform 1s;\n}\n",
+		},
+		{
+			"firefox 15",
+			"a {\n  background: linear-gradient(to right, red, blue);\n" +
+				"  width: calc(100% - 1px) !important;\n}\n",
+			"a {\n  background: -moz-linear-gradient(left, red, blue);\n" +
+				"  background: linear-gradient(to right, red, blue);\n" +
+				"  width: -moz-calc(100% - 1px) !important;\n" +
+				"  width: calc(100% - 1px) !important;\n}\n",
+		},
+		{
+			"chrome 25",
+			"a {\n  background: linear-gradient(45deg, red, blue);\n}\n",
+			"a {\n  background: -webkit-linear-gradient(45deg, red, blue);\n" +
+				"  background: linear-gradient(45deg, red, blue);\n}\n",
+		},
+		{
+			"ie 11, firefox 40",
+			"input::placeholder {\n  color: red;\n}\n",
+			"input::-moz-placeholder {\n  color: red;\n}\n" +
+				"input:-ms-input-placeholder {\n  color: red;\n}\n" +
+				"input::placeholder {\n  color: red;\n}\n",
+		},
+		{
+			"chrome 30",
+			"@keyframes spin {\n  to {\n    transform: rotate(1turn);\n  }\n}\n",
+			"@-webkit-keyframes spin {\n  to {\n    -webkit-transform: rotate(1turn);\n" +
+				"    transform: rotate(1turn);\n  }\n}\n" +
+				"@keyframes spin {\n  to {\n    -webkit-transform: rotate(1turn);\n" +
+				"    transform: rotate(1turn);\n  }\n}\n",
+		},
+		{
+			"chrome 40",
+			"@keyframes spin {\n  to {\n    transform: rotate(1turn);\n  }\n}\n",
+			"@-webkit-keyframes spin {\n  to {\n    transform: rotate(1turn);\n  }\n}\n" +
+				"@keyframes spin {\n  to {\n    transform: rotate(1turn);\n  }\n}\n",
+		},
+		{
+			"chrome 30",
+			"@media print {\n  a {\n    transform: none;\n  }\n}\n",
+			"@media print {\n  a {\n    -webkit-transform: none;\n" +
+				"    transform: none;\n  }\n}\n",
+		},
+		// Obsolete prefixes are removed
+		{
+			"chrome 120",
+			"a {\n  -webkit-transform: none;\n  -moz-user-select: none;\n" +
+				"  display: -ms-flexbox;\n  -webkit-unknown: 1;\n  transform: none;\n}\n" +
+				"::-moz-selection {\n  color: red;\n}\n" +
+				"@-webkit-keyframes spin {\n  to {\n    color: red;\n  }\n}\n",
+			"a {\n  -webkit-unknown: 1;\n  transform: none;\n}\n",
+		},
+		// Existing prefixes are not duplicated
+		{
+			"chrome 30",
+			"a {\n  -webkit-transform: scale(2);\n  transform: none;\n}\n",
+			"a {\n  -webkit-transform: scale(2);\n  transform: none;\n}\n",
+		},
+		{
+			"chrome 30",
+			"a{transform:none}",
+			"a{-webkit-transform:none;transform:none}",
+		},
+	}
+
+	for _, test := range tests {
+		s := &wt.Stylesheet{
+			CSS:  []byte(test.in),
+			Args: &wt.BuildArgs{Browsers: test.browsers},
+		}
+		if err := Autoprefix(s); err != nil {
+			t.Errorf("%s: %s", test.browsers, err)
+			continue
+		}
+		if string(s.CSS) != test.out {
+			t.Errorf("%s:\ngot: %q\nwanted: %q", test.browsers, s.CSS, test.out)
+		}
+	}
+}
+
+func TestAutoprefix_sourceMap(t *testing.T) {
+	s := &wt.Stylesheet{
+		CSS: []byte("a {\n  user-select: none;\n}\n"),
+		SourceMap: []byte(`{"version":3,"sources":["a.scss"],` +
+			`"mappings":"AAAA;EACE"}`),
+		Args: &wt.BuildArgs{Browsers: "safari 18.1"},
+	}
+	if err := Autoprefix(s); err != nil {
+		t.Fatal(err)
+	}
+	if e := "a {\n  -webkit-user-select: none;\n  user-select: none;\n}\n"; string(s.CSS) != e {
+		t.Errorf("got: %q wanted: %q", s.CSS, e)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(s.SourceMap, &m); err != nil {
+		t.Fatal(err)
+	}
+	// both declarations map to line 2 of a.scss
+	if e := "AAAA;EACE;EAAA"; m["mappings"] != e {
+		t.Errorf("got: %v wanted: %s", m["mappings"], e)
+	}
+	if m["sources"] == nil {
+		t.Error("source map fields were dropped")
+	}
+}
+
+func TestAutoprefix_invalid(t *testing.T) {
+	s := &wt.Stylesheet{
+		CSS:  []byte("a {}"),
+		Args: &wt.BuildArgs{Browsers: "netscape 4"},
+	}
+	err := Autoprefix(s)
+	if e := "unknown browser: netscape"; err == nil || !strings.Contains(err.Error(), e) {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+}
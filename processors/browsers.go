@@ -0,0 +1,370 @@
+package processors
+
+//go:generate go run gen_browsers.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// prefixOrder is the order prefixed copies are written in
+var prefixOrder = []string{"webkit", "moz", "ms", "o"}
+
+// agent is a browser in the support table
+type agent struct {
+	// Prefix is used by every version not listed in Prefixes
+	Prefix string `json:"prefix"`
+	// Prefixes maps other prefixes to the versions using them
+	Prefixes map[string]string `json:"prefixes"`
+	// Dead browsers are only selected by name
+	Dead bool `json:"dead"`
+	// Versions are released versions oldest first, whole numbers may
+	// be written as ranges ie. 4-131
+	Versions []string `json:"versions"`
+
+	versions []version
+}
+
+// feature is a property, value, function, selector or at-rule needing
+// a prefix in some browsers
+type feature struct {
+	// Properties limits values and functions to these properties, *
+	// matches every property
+	Properties []string `json:"properties"`
+	// Agents maps browsers to the versions needing a prefix ie. 4-35,
+	// 10 or 3.1+ for every version since 3.1
+	Agents map[string]string `json:"agents"`
+	// Names are the prefixed forms that are not -prefix-name
+	Names map[string]string `json:"names"`
+
+	name string
+}
+
+// prefixed returns the name of the feature with prefix
+func (f *feature) prefixed(prefix string) string {
+	if name, ok := f.Names[prefix]; ok {
+		return name
+	}
+	return "-" + prefix + "-" + f.name
+}
+
+// appliesTo reports whether a value or function is prefixed in prop
+func (f *feature) appliesTo(prop string) bool {
+	for _, p := range f.Properties {
+		if p == "*" || p == prop {
+			return true
+		}
+	}
+	return false
+}
+
+// table is the browser support table, see browsers.json
+type table struct {
+	Agents     map[string]*agent   `json:"agents"`
+	Aliases    map[string]string   `json:"aliases"`
+	Properties map[string]*feature `json:"properties"`
+	Values     map[string]*feature `json:"values"`
+	Functions  map[string]*feature `json:"functions"`
+	Selectors  map[string]*feature `json:"selectors"`
+	AtRules    map[string]*feature `json:"at-rules"`
+}
+
+// browsers is the support table embedded from browsers.json
+var browsers = mustTable(browsersJSON)
+
+func mustTable(s string) *table {
+	t, err := readTable(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// readTable decodes a support table, expanding version ranges and
+// features listed together ie. "transform, transform-origin"
+func readTable(s string) (*table, error) {
+	t := &table{}
+	if err := json.Unmarshal([]byte(s), t); err != nil {
+		return nil, err
+	}
+	for name, a := range t.Agents {
+		for _, v := range a.Versions {
+			vs, err := expandVersions(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			a.versions = append(a.versions, vs...)
+		}
+	}
+	for _, m := range []*map[string]*feature{&t.Properties, &t.Values,
+		&t.Functions, &t.Selectors, &t.AtRules} {
+		expanded := make(map[string]*feature)
+		for names, f := range *m {
+			for name := range f.Agents {
+				if _, ok := t.Agents[name]; !ok {
+					return nil, fmt.Errorf("%s: unknown browser %s", names, name)
+				}
+			}
+			for _, name := range strings.Split(names, ",") {
+				name = strings.TrimSpace(name)
+				cp := *f
+				cp.name = name
+				expanded[name] = &cp
+			}
+		}
+		*m = expanded
+	}
+	return t, nil
+}
+
+// version is a browser version ie. 4.4.4
+type version struct {
+	name  string
+	parts []int
+}
+
+func parseVersion(s string) (version, error) {
+	v := version{name: s}
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, fmt.Errorf("invalid version: %s", s)
+		}
+		v.parts = append(v.parts, n)
+	}
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 as v is older, the same or newer than o
+func (v version) compare(o version) int {
+	for i := 0; i < len(v.parts) || i < len(o.parts); i++ {
+		var a, b int
+		if i < len(v.parts) {
+			a = v.parts[i]
+		}
+		if i < len(o.parts) {
+			b = o.parts[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// expandVersions expands ranges of whole versions ie. 4-6 is 4, 5, 6
+func expandVersions(s string) ([]version, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) == 1 {
+		v, err := parseVersion(s)
+		return []version{v}, err
+	}
+	lo, err1 := strconv.Atoi(parts[0])
+	hi, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || lo > hi {
+		return nil, fmt.Errorf("invalid version range: %s", s)
+	}
+	var vs []version
+	for n := lo; n <= hi; n++ {
+		vs = append(vs, version{name: strconv.Itoa(n), parts: []int{n}})
+	}
+	return vs, nil
+}
+
+// inRange reports whether v is in a range of versions ie. 4-35, 10 or
+// 3.1+
+func inRange(v version, r string) bool {
+	if strings.HasSuffix(r, "+") {
+		lo, err := parseVersion(strings.TrimSuffix(r, "+"))
+		return err == nil && v.compare(lo) >= 0
+	}
+	parts := strings.SplitN(r, "-", 2)
+	lo, err := parseVersion(parts[0])
+	if err != nil {
+		return false
+	}
+	hi := lo
+	if len(parts) == 2 {
+		if hi, err = parseVersion(parts[1]); err != nil {
+			return false
+		}
+	}
+	return v.compare(lo) >= 0 && v.compare(hi) <= 0
+}
+
+// prefixOf returns the prefix used by version v of a
+func (a *agent) prefixOf(v version) string {
+	for prefix, r := range a.Prefixes {
+		if inRange(v, r) {
+			return prefix
+		}
+	}
+	return a.Prefix
+}
+
+// selection is the browser versions chosen by a query
+type selection map[string]map[string]version
+
+func (s selection) add(name string, v version) {
+	if s[name] == nil {
+		s[name] = make(map[string]version)
+	}
+	s[name][v.name] = v
+}
+
+// prefixes returns the prefixes of f needed by the selected browsers
+// in prefixOrder
+func (t *table) prefixes(f *feature, sel selection) []string {
+	needed := make(map[string]bool)
+	for name, r := range f.Agents {
+		a := t.Agents[name]
+		for _, v := range sel[name] {
+			if inRange(v, r) {
+				needed[a.prefixOf(v)] = true
+			}
+		}
+	}
+	var prefixes []string
+	for _, prefix := range prefixOrder {
+		if needed[prefix] {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// agent returns a browser by name or alias
+func (t *table) agent(name string) (string, *agent, error) {
+	name = strings.ToLower(name)
+	if alias, ok := t.Aliases[name]; ok {
+		name = alias
+	}
+	a, ok := t.Agents[name]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown browser: %s", name)
+	}
+	return name, a, nil
+}
+
+// query selects browser versions with a subset of the browserslist
+// syntax. Queries are separated by commas and combined, a query
+// starting with not removes versions.
+//
+//	defaults              last 2 versions
+//	last 2 versions       the newest versions of every living browser
+//	last 2 chrome versions
+//	firefox >= 60         also >, <, <= and an exact version
+//	not dead              browsers without updates are never selected
+//	                      unless named
+func (t *table) query(q string) (selection, error) {
+	sel := make(selection)
+	for _, part := range strings.Split(q, ",") {
+		part = strings.ToLower(strings.Join(strings.Fields(part), " "))
+		if len(part) == 0 || part == "not dead" {
+			continue
+		}
+		not := strings.HasPrefix(part, "not ")
+		part = strings.TrimPrefix(part, "not ")
+		if part == "defaults" {
+			part = "last 2 versions"
+		}
+		matched, err := t.match(part)
+		if err != nil {
+			return nil, err
+		}
+		for name, vs := range matched {
+			for _, v := range vs {
+				if not {
+					delete(sel[name], v.name)
+					continue
+				}
+				sel.add(name, v)
+			}
+		}
+	}
+	return sel, nil
+}
+
+// match evaluates a single query
+func (t *table) match(q string) (map[string][]version, error) {
+	matched := make(map[string][]version)
+	f := strings.Fields(q)
+	switch {
+	case len(f) == 3 && f[0] == "last" && strings.HasPrefix(f[2], "version"):
+		n, err := strconv.Atoi(f[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid browser query: %s", q)
+		}
+		for name, a := range t.Agents {
+			if !a.Dead {
+				matched[name] = last(a.versions, n)
+			}
+		}
+		return matched, nil
+	case len(f) == 4 && f[0] == "last" && strings.HasPrefix(f[3], "version"):
+		n, err := strconv.Atoi(f[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid browser query: %s", q)
+		}
+		name, a, err := t.agent(f[2])
+		if err != nil {
+			return nil, err
+		}
+		matched[name] = last(a.versions, n)
+		return matched, nil
+	case len(f) == 2 || len(f) == 3:
+		name, a, err := t.agent(f[0])
+		if err != nil {
+			return nil, err
+		}
+		op, vs := "=", f[len(f)-1]
+		if len(f) == 3 {
+			op = f[1]
+		}
+		v, err := parseVersion(vs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid browser query: %s", q)
+		}
+		for _, av := range a.versions {
+			c := av.compare(v)
+			ok := false
+			switch op {
+			case "=":
+				ok = c == 0
+			case ">=":
+				ok = c >= 0
+			case ">":
+				ok = c > 0
+			case "<=":
+				ok = c <= 0
+			case "<":
+				ok = c < 0
+			default:
+				return nil, fmt.Errorf("invalid browser query: %s", q)
+			}
+			if ok {
+				matched[name] = append(matched[name], av)
+			}
+		}
+		return matched, nil
+	}
+	return nil, fmt.Errorf("invalid browser query: %s", q)
+}
+
+// last returns the newest n versions
+func last(vs []version, n int) []version {
+	sorted := append([]version{}, vs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].compare(sorted[j]) < 0
+	})
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	return sorted[len(sorted)-n:]
+}
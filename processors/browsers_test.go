@@ -0,0 +1,102 @@
+package processors
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func selected(sel selection) map[string][]string {
+	m := make(map[string][]string)
+	for name, vs := range sel {
+		for v := range vs {
+			m[name] = append(m[name], v)
+		}
+		sort.Strings(m[name])
+	}
+	return m
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		q string
+		e map[string][]string
+	}{
+		{"ie >= 10", map[string][]string{"ie": {"10", "11"}}},
+		{"IE 11, ff < 3", map[string][]string{"ie": {"11"}, "firefox": {"2"}}},
+		{"last 2 chrome versions", map[string][]string{"chrome": {"130", "131"}}},
+		{"safari > 18, not safari 18.1", map[string][]string{"safari": {}}},
+		{"android 4.4.4", map[string][]string{"android": {"4.4.4"}}},
+	}
+	for _, test := range tests {
+		sel, err := browsers.query(test.q)
+		if err != nil {
+			t.Errorf("%s: %s", test.q, err)
+			continue
+		}
+		got := selected(sel)
+		for name, vs := range test.e {
+			if len(vs) == 0 && len(got[name]) == 0 {
+				delete(got, name)
+			}
+		}
+		for name, vs := range test.e {
+			if len(vs) == 0 {
+				delete(test.e, name)
+			}
+		}
+		if !reflect.DeepEqual(got, test.e) {
+			t.Errorf("%s: got: %v wanted: %v", test.q, got, test.e)
+		}
+	}
+
+	sel, err := browsers.query("defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sel["ie"]; ok {
+		t.Error("defaults selected a dead browser")
+	}
+	if len(sel["chrome"]) != 2 {
+		t.Errorf("got: %v wanted 2 chrome versions", sel["chrome"])
+	}
+
+	for _, q := range []string{"chrome", "chrome ~ 4", "last x versions", "chrome 4.x"} {
+		if _, err := browsers.query(q); err == nil {
+			t.Errorf("%s: expected an error", q)
+		}
+	}
+}
+
+func TestTable_prefixes(t *testing.T) {
+	sel, err := browsers.query("chrome 50, ie 10, edge 80, firefox 60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := browsers.Properties["user-select"]
+	if e, got := []string{"webkit", "moz", "ms"}, browsers.prefixes(f, sel); !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	// EdgeHTML used the ms prefix
+	sel, err = browsers.query("edge 18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, got := []string{"ms"}, browsers.prefixes(f, sel); !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+	if e := "-ms-flex-order"; browsers.Properties["order"].prefixed("ms") != e {
+		t.Errorf("got: %s wanted: %s", browsers.Properties["order"].prefixed("ms"), e)
+	}
+}
+
+func TestReadTable(t *testing.T) {
+	_, err := readTable(`{"agents": {}, "properties": {"a, b": {"agents": {"x": "1"}}}}`)
+	if e := "a, b: unknown browser x"; err == nil || err.Error() != e {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+	_, err = readTable(`{"agents": {"x": {"versions": ["5-1"]}}}`)
+	if e := "x: invalid version range: 5-1"; err == nil || err.Error() != e {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+}
@@ -0,0 +1,251 @@
+package processors
+
+import "strings"
+
+type nodeKind int
+
+const (
+	declNode nodeKind = iota
+	ruleNode
+	atNode
+)
+
+// node is a declaration, rule or at-rule in compiled CSS. Positions
+// are byte offsets into the parsed text.
+type node struct {
+	kind nodeKind
+	// sep is where the whitespace and comments before the node start
+	sep        int
+	start, end int
+
+	// head is the selector of a rule, the name and prelude of an at-rule
+	// or the property of a declaration
+	head    string
+	headEnd int
+
+	// name is the at-rule name without @ ie. keyframes
+	name    string
+	nameEnd int
+
+	// value of a declaration, including any !important
+	value                string
+	valueStart, valueEnd int
+	// semi is true when the declaration ends in a semicolon
+	semi bool
+
+	// body is the position after the opening brace, -1 without a block
+	body     int
+	children []*node
+}
+
+// important reports whether the declaration is !important
+func (n *node) important() bool {
+	return strings.HasSuffix(strings.ToLower(n.value), "important")
+}
+
+// plainValue is the value of a declaration without !important
+func (n *node) plainValue() string {
+	v := n.value
+	if i := strings.LastIndex(v, "!"); i >= 0 && n.important() {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
+
+// parseCSS parses the rules of a stylesheet. The parser is forgiving,
+// it expects the well formed output of a compiler.
+func parseCSS(css string) []*node {
+	p := &parser{s: css}
+	return p.block()
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+// skip moves past whitespace and comments
+func (p *parser) skip() {
+	for p.pos < len(p.s) {
+		switch {
+		case isSpace(p.s[p.pos]):
+			p.pos++
+		case strings.HasPrefix(p.s[p.pos:], "/*"):
+			end := strings.Index(p.s[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.s)
+				return
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+// scan moves to the first of stop outside of strings, comments and
+// parentheses, returning it or 0 at the end of the text
+func (p *parser) scan(stop string) byte {
+	depth := 0
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == '"' || c == '\'':
+			p.pos++
+			for p.pos < len(p.s) && p.s[p.pos] != c {
+				if p.s[p.pos] == '\\' {
+					p.pos++
+				}
+				p.pos++
+			}
+		case c == '/' && strings.HasPrefix(p.s[p.pos:], "/*"):
+			end := strings.Index(p.s[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.s)
+				return 0
+			}
+			p.pos += end + 3
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && strings.IndexByte(stop, c) >= 0:
+			return c
+		}
+		p.pos++
+	}
+	return 0
+}
+
+// block parses nodes until a closing brace or the end of the text
+func (p *parser) block() []*node {
+	var nodes []*node
+	for {
+		sep := p.pos
+		p.skip()
+		if p.pos >= len(p.s) {
+			return nodes
+		}
+		if p.s[p.pos] == '}' {
+			return nodes
+		}
+		if p.s[p.pos] == ';' {
+			p.pos++
+			continue
+		}
+		n := &node{sep: sep, start: p.pos, body: -1}
+		switch p.scan("{;}") {
+		case '{':
+			n.headEnd = trimRight(p.s, n.start, p.pos)
+			n.head = p.s[n.start:n.headEnd]
+			n.kind = ruleNode
+			if strings.HasPrefix(n.head, "@") {
+				n.kind = atNode
+				n.nameEnd = n.start + 1
+				for n.nameEnd < n.headEnd && isNameChar(p.s[n.nameEnd]) {
+					n.nameEnd++
+				}
+				n.name = strings.ToLower(p.s[n.start+1 : n.nameEnd])
+			}
+			p.pos++
+			n.body = p.pos
+			n.children = p.block()
+			if p.pos < len(p.s) {
+				p.pos++
+			}
+			n.end = p.pos
+		default:
+			end := p.pos
+			n.headEnd = trimRight(p.s, n.start, end)
+			n.end = n.headEnd
+			if end < len(p.s) && p.s[end] == ';' {
+				n.semi = true
+				p.pos++
+				n.end = p.pos
+			}
+			if strings.HasPrefix(p.s[n.start:], "@") {
+				n.kind = atNode
+				n.head = p.s[n.start:n.headEnd]
+				n.nameEnd = n.start + 1
+				for n.nameEnd < n.headEnd && isNameChar(p.s[n.nameEnd]) {
+					n.nameEnd++
+				}
+				n.name = strings.ToLower(p.s[n.start+1 : n.nameEnd])
+				break
+			}
+			p.decl(n)
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+// decl splits a declaration into its property and value
+func (p *parser) decl(n *node) {
+	n.kind = declNode
+	text := p.s[n.start:n.headEnd]
+	colon := strings.IndexByte(text, ':')
+	if colon < 0 {
+		n.head = text
+		n.valueStart, n.valueEnd = n.headEnd, n.headEnd
+		return
+	}
+	end := trimRight(p.s, n.start, n.start+colon)
+	n.head = strings.ToLower(p.s[n.start:end])
+	n.valueStart = n.start + colon + 1
+	for n.valueStart < n.headEnd && isSpace(p.s[n.valueStart]) {
+		n.valueStart++
+	}
+	n.valueEnd = n.headEnd
+	n.value = p.s[n.valueStart:n.valueEnd]
+	// the property ends before any space ahead of the colon
+	n.headEnd = end
+}
+
+func trimRight(s string, start, end int) int {
+	for end > start && isSpace(s[end-1]) {
+		end--
+	}
+	return end
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// indent returns the whitespace between the last comment before n and
+// n, it separates n from the node before it. The first node in the text
+// uses the whitespace after it.
+func indent(s string, n *node) string {
+	if n.sep == 0 && n.start == 0 {
+		end := n.end
+		for end < len(s) && isSpace(s[end]) {
+			end++
+		}
+		return s[n.end:end]
+	}
+	sep := s[n.sep:n.start]
+	if i := strings.LastIndex(sep, "*/"); i >= 0 {
+		sep = sep[i+2:]
+	}
+	return sep
+}
+
+// splitPrefix splits a vendor prefix from name ie. -webkit-transform is
+// webkit and transform
+func splitPrefix(name string) (string, string) {
+	if !strings.HasPrefix(name, "-") || strings.HasPrefix(name, "--") {
+		return "", name
+	}
+	i := strings.IndexByte(name[1:], '-')
+	if i < 0 {
+		return "", name
+	}
+	return name[1 : i+1], name[i+2:]
+}
@@ -0,0 +1,301 @@
+package processors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// edit replaces the text between start and end. Inserted text is mapped
+// to the same source as the text at origin.
+type edit struct {
+	start, end int
+	text       string
+	origin     int
+}
+
+func insert(at int, text string) edit {
+	return edit{start: at, end: at, text: text, origin: at}
+}
+
+func remove(start, end int) edit {
+	return edit{start: start, end: end, origin: start}
+}
+
+// applyEdits returns s with edits applied, edits must not overlap
+func applyEdits(s string, edits []edit) string {
+	sortEdits(edits)
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		buf.WriteString(s[last:e.start])
+		buf.WriteString(e.text)
+		last = e.end
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+func sortEdits(edits []edit) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+}
+
+// shift edits found in text starting at offset so they apply to the text
+// alone
+func shift(edits []edit, offset int) []edit {
+	shifted := make([]edit, len(edits))
+	for i, e := range edits {
+		shifted[i] = edit{
+			start:  e.start - offset,
+			end:    e.end - offset,
+			text:   e.text,
+			origin: e.origin - offset,
+		}
+	}
+	return shifted
+}
+
+// segment is a decoded source map mapping, fields after the generated
+// column are copied unchanged
+type segment struct {
+	line, col int
+	fields    []int
+}
+
+// remap moves the mappings of a source map generated for css to match
+// the text after edits. Mappings of removed text are dropped, inserted
+// text is mapped to its origin on each line.
+func remap(smap []byte, css string, edits []edit) ([]byte, error) {
+	if len(smap) == 0 || len(edits) == 0 {
+		return smap, nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(smap, &m); err != nil {
+		return nil, err
+	}
+	mappings, ok := m["mappings"].(string)
+	if !ok {
+		return nil, errors.New("source map has no mappings")
+	}
+	segs, err := decodeMappings(mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	sortEdits(edits)
+	oldLines := lineStarts(css)
+	out := applyEdits(css, edits)
+	newLines := lineStarts(out)
+
+	// newOffset moves an offset in css past the edits before it, text
+	// inserted at the offset comes first
+	newOffset := func(off int) (int, bool) {
+		delta := 0
+		for _, e := range edits {
+			if e.start > off {
+				break
+			}
+			if e.start == e.end {
+				delta += len(e.text)
+				continue
+			}
+			if off < e.end {
+				return 0, false
+			}
+			delta += len(e.text) - (e.end - e.start)
+		}
+		return off + delta, true
+	}
+	// sourceAt finds the segment covering the offset in css
+	offsets := make([]int, len(segs))
+	for i, s := range segs {
+		offsets[i] = toOffset(oldLines, s.line, s.col)
+	}
+	sourceAt := func(off int) (segment, bool) {
+		i := sort.SearchInts(offsets, off+1) - 1
+		if i < 0 {
+			return segment{}, false
+		}
+		return segs[i], true
+	}
+
+	var moved []segment
+	for i, s := range segs {
+		off, ok := newOffset(offsets[i])
+		if !ok {
+			continue
+		}
+		line, col := toLineCol(newLines, off)
+		moved = append(moved, segment{line: line, col: col, fields: s.fields})
+	}
+	delta := 0
+	for _, e := range edits {
+		start := e.start + delta
+		delta += len(e.text) - (e.end - e.start)
+		if len(e.text) == 0 {
+			continue
+		}
+		src, ok := sourceAt(e.origin)
+		if !ok {
+			continue
+		}
+		// map the start of each line of the inserted text
+		for i := 0; i < len(e.text); {
+			line, col := toLineCol(newLines, start+i)
+			moved = append(moved, segment{line: line, col: col, fields: src.fields})
+			next := strings.IndexByte(e.text[i:], '\n')
+			if next < 0 {
+				break
+			}
+			i += next + 1
+			for i < len(e.text) && isSpace(e.text[i]) && e.text[i] != '\n' {
+				i++
+			}
+		}
+	}
+	sort.SliceStable(moved, func(i, j int) bool {
+		if moved[i].line != moved[j].line {
+			return moved[i].line < moved[j].line
+		}
+		return moved[i].col < moved[j].col
+	})
+
+	m["mappings"] = encodeMappings(moved)
+	return json.Marshal(m)
+}
+
+// lineStarts returns the offset of each line in s
+func lineStarts(s string) []int {
+	starts := []int{0}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func toOffset(lines []int, line, col int) int {
+	if line >= len(lines) {
+		line = len(lines) - 1
+	}
+	return lines[line] + col
+}
+
+func toLineCol(lines []int, off int) (int, int) {
+	line := sort.SearchInts(lines, off+1) - 1
+	return line, off - lines[line]
+}
+
+const base64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeMappings decodes the VLQ mappings of a version 3 source map
+// into absolute positions
+func decodeMappings(s string) ([]segment, error) {
+	var segs []segment
+	// fields after the generated column are relative across lines
+	prev := make([]int, 4)
+	line, col := 0, 0
+	for len(s) > 0 {
+		switch s[0] {
+		case ';':
+			line++
+			col = 0
+			s = s[1:]
+			continue
+		case ',':
+			s = s[1:]
+			continue
+		}
+		var vals []int
+		for len(s) > 0 && s[0] != ',' && s[0] != ';' {
+			var v int
+			var err error
+			v, s, err = decodeVLQ(s)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, v)
+		}
+		col += vals[0]
+		seg := segment{line: line, col: col}
+		for i, v := range vals[1:] {
+			if i >= len(prev) {
+				break
+			}
+			prev[i] += v
+			seg.fields = append(seg.fields, prev[i])
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+func decodeVLQ(s string) (int, string, error) {
+	var v, shift uint
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(base64, s[i])
+		if digit < 0 {
+			return 0, "", errors.New("invalid source map mapping")
+		}
+		v |= uint(digit&31) << shift
+		shift += 5
+		if digit&32 == 0 {
+			n := int(v >> 1)
+			if v&1 == 1 {
+				n = -n
+			}
+			return n, s[i+1:], nil
+		}
+	}
+	return 0, "", errors.New("truncated source map mapping")
+}
+
+// encodeMappings encodes segments sorted by position
+func encodeMappings(segs []segment) string {
+	var buf bytes.Buffer
+	prev := make([]int, 4)
+	line, col := 0, 0
+	first := true
+	for _, s := range segs {
+		for line < s.line {
+			buf.WriteByte(';')
+			line++
+			col = 0
+			first = true
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		encodeVLQ(&buf, s.col-col)
+		col = s.col
+		for i, f := range s.fields {
+			encodeVLQ(&buf, f-prev[i])
+			prev[i] = f
+		}
+	}
+	return buf.String()
+}
+
+func encodeVLQ(buf *bytes.Buffer, n int) {
+	v := uint(n) << 1
+	if n < 0 {
+		v = uint(-n)<<1 | 1
+	}
+	for {
+		digit := v & 31
+		v >>= 5
+		if v > 0 {
+			digit |= 32
+		}
+		buf.WriteByte(base64[digit])
+		if v == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package processors
+
+import "testing"
+
+func TestMappings_roundTrip(t *testing.T) {
+	for _, m := range []string{"AAAA;EACE;EAAA", "AAAA,CAAC;;ACDA,gBAAgB", ";;AAAAA"} {
+		segs, err := decodeMappings(m)
+		if err != nil {
+			t.Errorf("%s: %s", m, err)
+			continue
+		}
+		if got := encodeMappings(segs); got != m {
+			t.Errorf("got: %s wanted: %s", got, m)
+		}
+	}
+	if _, err := decodeMappings("A!"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	s := "a {\n  b: c;\n  d: e;\n}\n"
+	got := applyEdits(s, []edit{
+		remove(11, 19),
+		insert(6, "x: y;\n  "),
+	})
+	if e := "a {\n  x: y;\n  b: c;\n}\n"; got != e {
+		t.Errorf("got: %q wanted: %q", got, e)
+	}
+}
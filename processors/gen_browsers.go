@@ -0,0 +1,39 @@
+//go:build ignore
+// +build ignore
+
+// gen_browsers embeds browsers.json in browsers_data.go, run it with
+// go generate after updating the table
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+func main() {
+	bs, err := ioutil.ReadFile("browsers.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(bs, &v); err != nil {
+		log.Fatalf("browsers.json: %s", err)
+	}
+	if bytes.IndexByte(bs, '`') >= 0 {
+		log.Fatal("browsers.json: backquotes are not supported")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by gen_browsers.go from browsers.json; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package processors")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "const browsersJSON = `%s`\n", strings.TrimSpace(string(bs)))
+	if err := ioutil.WriteFile("browsers_data.go", buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,284 @@
+package wellington
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wellington/spritewell"
+	"github.com/wellington/wellington/payload"
+)
+
+// spriteName matches the sprites written by spritewell and their
+// content hashed copies
+var spriteName = regexp.MustCompile(`^[0-9a-f]{6}(\.[0-9a-f]{8})?\.png$`)
+
+// absPath returns the absolute form of path, or path if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// sprites waits for every sprite in the payload to be written and
+// returns the absolute paths of the files in Gen produced by the Build.
+// Sprites recorded in the cache are included when the cache skipped
+// any files, as those files did not request their sprites.
+func (b *Build) sprites() (map[string]bool, error) {
+	gba := b.bArgs
+	if err := payload.Wait(gba.Payload); err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool)
+	var err error
+	payload.Sprite(gba.Payload).ForEach(func(_ string, sprite *spritewell.Sprite) {
+		var out string
+		out, err = sprite.OutputPath()
+		if err == nil {
+			keep[absPath(filepath.Join(gba.Gen, filepath.Base(out)))] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if hashed := payload.Hashed(gba.Payload); hashed != nil {
+		hashed.ForEach(func(_, out string) {
+			keep[absPath(out)] = true
+		})
+	}
+
+	if b.cache != nil {
+		skipped := false
+		b.mu.Lock()
+		for _, f := range b.files {
+			skipped = skipped || f.Cached
+		}
+		b.mu.Unlock()
+		if skipped {
+			for _, out := range b.cache.prevSprites {
+				keep[out] = true
+			}
+		}
+	}
+	return keep, nil
+}
+
+// ErrPruneRecord is returned when pruning a build that keeps no record
+// of its outputs
+var ErrPruneRecord = errors.New("prune requires a cache or manifest recording the outputs of the last build")
+
+// recorded returns the outputs of the last Build recorded by its cache
+// and manifest: CSS, its parts and RTL copy, source maps, sprites and
+// the content hashed copies of assets
+func (b *Build) recorded() []string {
+	var files []string
+	if b.cache != nil {
+		files = append(files, b.cache.recorded...)
+	}
+	s := b.bArgs.manifest
+	if s == nil {
+		return files
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prev == nil {
+		return files
+	}
+	dir := b.bArgs.manifestDir()
+	abs := func(rel string) string {
+		return filepath.Join(dir, filepath.FromSlash(rel))
+	}
+	for _, f := range s.prev.Files {
+		files = append(files, abs(f.Output))
+		if len(f.SourceMap) > 0 {
+			files = append(files, abs(f.SourceMap))
+		}
+		for _, part := range f.Parts {
+			files = append(files, abs(part))
+		}
+		if len(f.RTL) > 0 {
+			files = append(files, abs(f.RTL))
+		}
+	}
+	for _, sprite := range s.prev.Sprites {
+		files = append(files, abs(sprite.Output))
+	}
+	for _, a := range s.prev.Assets {
+		files = append(files, abs(a.Output))
+	}
+	return files
+}
+
+// inDir reports whether path is inside one of dirs
+func inDir(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune removes the outputs the last builds recorded in their cache or
+// manifest that builds did not produce: CSS, its parts and RTL copy,
+// source maps, compressed copies, sprites and the content hashed copies
+// of assets. Only files in the BuildDir or Gen of builds are removed,
+// the working directory is never pruned. Builds sharing directories are
+// pruned together so they do not remove each other's files. The removed
+// files are returned.
+func Prune(builds ...*Build) ([]string, error) {
+	keep := make(map[string]bool)
+	// exts are the compressed copies still written, see Compress
+	exts := make(map[string]bool)
+	var dirs, recorded []string
+	wd := absPath(".")
+	for _, b := range builds {
+		gba := b.bArgs
+		if len(gba.Cache) == 0 && len(gba.Manifest) == 0 {
+			return nil, ErrPruneRecord
+		}
+		for _, format := range gba.Compress {
+			exts[compressors[format].ext] = true
+		}
+		b.mu.Lock()
+		for _, f := range b.files {
+			keep[absPath(f.Output)] = true
+			for _, part := range f.Parts {
+				keep[absPath(part)] = true
+			}
+			if len(f.RTL) > 0 {
+				keep[absPath(f.RTL)] = true
+			}
+			// a file that can no longer be read keeps the build's
+			// source map setting
+			fa, err := gba.fileArgs(f.Input)
+			if err != nil {
+				fa = gba
+			}
+			// split CSS has no source map
+			if fa.SourceMap && len(f.Parts) == 0 {
+				keep[absPath(fa.outPath(f.Input)+".map")] = true
+			}
+		}
+		b.mu.Unlock()
+
+		sprites, err := b.sprites()
+		if err != nil {
+			return nil, err
+		}
+		for sprite := range sprites {
+			keep[sprite] = true
+		}
+		recorded = append(recorded, b.recorded()...)
+		if len(gba.BuildDir) > 0 {
+			dirs = appendUnique(dirs, absPath(gba.BuildDir))
+		}
+		// Without Gen sprites are written to the working directory
+		if gen := absPath(gba.Gen); len(gba.Gen) > 0 &&
+			gen != wd && gen != absPath(gba.WorkDir) {
+			dirs = appendUnique(dirs, gen)
+		}
+	}
+
+	var removed []string
+	remove := func(path string) error {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err == nil {
+			removed = append(removed, path)
+		}
+		return err
+	}
+	for _, path := range recorded {
+		path = absPath(path)
+		if !inDir(path, dirs) {
+			continue
+		}
+		names := []string{path}
+		if strings.HasSuffix(path, ".css") {
+			names = append(names, path+".map")
+		}
+		for _, name := range names {
+			if !keep[name] {
+				if err := remove(name); err != nil {
+					return removed, err
+				}
+			}
+			for _, c := range compressors {
+				if keep[name] && exts[c.ext] {
+					continue
+				}
+				if err := remove(name + c.ext); err != nil {
+					return removed, err
+				}
+			}
+		}
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// hashedCSS matches the end of a content hashed CSS name, see hashName
+var hashedCSS = regexp.MustCompile(`^\.[0-9a-f]{8}\.css$`)
+
+// partImport matches the imports of the parts of split CSS
+var partImport = regexp.MustCompile(`@import url\("([^"]+)"\);`)
+
+// removeOutput deletes the CSS built from path, a Sass file that no
+// longer exists, with the files derived from it: content hashed names,
+// parts, the RTL copy, source maps and compressed copies. The removed
+// files are returned.
+func (b *BuildArgs) removeOutput(path string) ([]string, error) {
+	if b == nil || len(b.BuildDir) == 0 {
+		return nil, nil
+	}
+	name := b.outPath(path)
+	var files []string
+	for _, css := range []string{name, rtlPath(name)} {
+		stem := strings.TrimSuffix(css, ".css")
+		hashed, _ := filepath.Glob(stem + ".*.css")
+		for _, f := range append([]string{css}, hashed...) {
+			if f != css && !hashedCSS.MatchString(strings.TrimPrefix(f, stem)) {
+				continue
+			}
+			files = append(files, f, f+".map")
+			bs, err := ioutil.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			for _, m := range partImport.FindAllStringSubmatch(string(bs), -1) {
+				if strings.HasPrefix(m[1], filepath.Base(stem)+"-") {
+					files = append(files, filepath.Join(filepath.Dir(f), m[1]))
+				}
+			}
+		}
+	}
+
+	var removed []string
+	for _, f := range files {
+		names := []string{f}
+		for _, c := range compressors {
+			names = append(names, f+c.ext)
+		}
+		for _, name := range names {
+			err := os.Remove(name)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return removed, err
+			}
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
@@ -0,0 +1,183 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuild_prune(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	bdir := filepath.Join(tdir, "build")
+	gdir := filepath.Join(tdir, "gen")
+	for _, dir := range []string{sdir, bdir, gdir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path, contents string) {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sprite := func(glob string) string {
+		return `$map: sprite-map("` + glob + `");
+div { width: sprite-width($map, "139"); background: sprite($map, "139"); }`
+	}
+	main := filepath.Join(sdir, "main.scss")
+	old := filepath.Join(sdir, "old.scss")
+	write(main, sprite("*.png"))
+	write(old, "div { color: red; }")
+	// Files wt did not write are never removed
+	others := []string{
+		filepath.Join(bdir, "vendor.css"),
+		filepath.Join(bdir, "vendor.css.map"),
+		filepath.Join(gdir, "abcdef.png"),
+	}
+	for _, path := range others {
+		write(path, "")
+	}
+
+	args := &BuildArgs{
+		BuildDir:  bdir,
+		ImageDir:  "test/img",
+		Gen:       gdir,
+		SourceMap: true,
+		Compress:  []string{"gzip"},
+		Manifest:  filepath.Join(bdir, "manifest.json"),
+		Prune:     true,
+	}
+	args.WithPaths([]string{sdir})
+	build := func() []string {
+		args.Payload = nil
+		b := NewBuild(args, NewPartialMap())
+		if err := b.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return b.Pruned()
+	}
+	if removed := build(); len(removed) > 0 {
+		t.Errorf("first build removed: %v", removed)
+	}
+	sprites, err := filepath.Glob(filepath.Join(gdir, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(old); err != nil {
+		t.Fatal(err)
+	}
+	write(main, sprite("13*.png"))
+	args.SourceMap = false
+	args.Compress = nil
+	build()
+
+	stale := []string{
+		filepath.Join(bdir, "old.css"),
+		filepath.Join(bdir, "old.css.map"),
+		filepath.Join(bdir, "old.css.gz"),
+		filepath.Join(bdir, "main.css.map"),
+		filepath.Join(bdir, "main.css.gz"),
+	}
+	for _, path := range sprites {
+		if filepath.Base(path) != "abcdef.png" {
+			stale = append(stale, path)
+		}
+	}
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("not removed: %s", path)
+		}
+	}
+	for _, path := range append(others, filepath.Join(bdir, "main.css")) {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("removed: %s", err)
+		}
+	}
+
+	// Sprites written to the working directory are never removed
+	sprites, err = filepath.Glob(filepath.Join(gdir, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args.WorkDir = gdir
+	write(main, sprite("*.png"))
+	build()
+	for _, path := range sprites {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("removed: %s", err)
+		}
+	}
+
+	args.Manifest = ""
+	if err := NewBuild(args, NewPartialMap()).Run(); err != ErrPruneRecord {
+		t.Errorf("got: %v wanted: %s", err, ErrPruneRecord)
+	}
+}
+
+func TestBuild_pruneHashed(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_prunehashed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	idir := filepath.Join(tdir, "img")
+	bdir := filepath.Join(tdir, "build")
+	for _, dir := range []string{sdir, idir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(sdir, "main.scss"),
+		[]byte(`div { background: image-url("logo.png"); }`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := filepath.Join(idir, "logo.png")
+	if err := ioutil.WriteFile(img, []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := &BuildArgs{
+		BuildDir:  bdir,
+		ImageDir:  idir,
+		CacheBust: "filename",
+		Manifest:  filepath.Join(bdir, "manifest.json"),
+		Prune:     true,
+	}
+	args.WithPaths([]string{sdir})
+	if err := NewBuild(args, NewPartialMap()).Run(); err != nil {
+		t.Fatal(err)
+	}
+	first, err := filepath.Glob(filepath.Join(bdir, "logo.*.png"))
+	if err != nil || len(first) != 1 {
+		t.Fatalf("got: %v %v wanted a hashed copy in the build directory", first, err)
+	}
+	// the source tree is left alone
+	if copies, _ := filepath.Glob(filepath.Join(idir, "logo.*.png")); len(copies) > 0 {
+		t.Errorf("hashed copies in the image directory: %v", copies)
+	}
+
+	if err := ioutil.WriteFile(img, []byte("two"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args.Payload = nil
+	if err := NewBuild(args, NewPartialMap()).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(first[0]); !os.IsNotExist(err) {
+		t.Errorf("old copy was not removed: %v", err)
+	}
+	if copies, _ := filepath.Glob(filepath.Join(bdir, "logo.*.png")); len(copies) != 1 {
+		t.Errorf("got: %v wanted the new copy", copies)
+	}
+}
@@ -0,0 +1,155 @@
+package wellington
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wellington/spritewell"
+	"github.com/wellington/wellington/payload"
+)
+
+// Report describes the outcome of a Build for tools like CI servers.
+// Durations are in seconds.
+type Report struct {
+	Files   []FileReport   `json:"files"`
+	Sprites []SpriteReport `json:"sprites"`
+}
+
+// FileReport is the outcome of building a single Sass file
+type FileReport struct {
+	Input  string `json:"input"`
+	Output string `json:"output,omitempty"`
+	// Parts are the CSS files Output and RTL were split into, see
+	// BuildArgs.MaxSelectors
+	Parts []string `json:"parts,omitempty"`
+	// RTL is the mirrored copy of Output, see BuildArgs.RTL
+	RTL string `json:"rtl,omitempty"`
+	// Cached is true when the output was not rebuilt, see BuildArgs.Cache
+	Cached   bool       `json:"cached,omitempty"`
+	Duration float64    `json:"duration"`
+	Bytes    int64      `json:"bytes"`
+	Warnings []string   `json:"warnings"`
+	Error    *FileError `json:"error,omitempty"`
+}
+
+// SpriteReport is the outcome of generating a sprite
+type SpriteReport struct {
+	Name     string  `json:"name"`
+	Output   string  `json:"output"`
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Report returns the outcome of the Build after Run, it waits for every
+// sprite to be written.
+func (b *Build) Report() *Report {
+	r := &Report{
+		Files:   []FileReport{},
+		Sprites: []SpriteReport{},
+	}
+	b.mu.Lock()
+	r.Files = append(r.Files, b.files...)
+	b.mu.Unlock()
+
+	ctx := b.bArgs.Payload
+	payload.Sprite(ctx).ForEach(func(key string, sprite *spritewell.Sprite) {
+		sr := SpriteReport{Name: key}
+		if err := payload.WaitSprite(ctx, sprite); err != nil {
+			sr.Error = err.Error()
+		}
+		sr.Duration = payload.Duration(ctx, sprite).Seconds()
+		if out, err := sprite.OutputPath(); err == nil {
+			sr.Output = filepath.Join(b.bArgs.Gen, filepath.Base(out))
+		}
+		r.Sprites = append(r.Sprites, sr)
+	})
+	sort.Slice(r.Sprites, func(i, j int) bool {
+		return r.Sprites[i].Name < r.Sprites[j].Name
+	})
+	return r
+}
+
+// Failed returns the number of files that failed to build
+func (r *Report) Failed() int {
+	var n int
+	for _, f := range r.Files {
+		if f.Error != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	bs, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(bs, '\n'))
+	return err
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as a JUnit XML test suite, each Sass
+// file is a test case failing when the file did not build.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suite := junitSuite{
+		Name:     "wellington",
+		Tests:    len(r.Files),
+		Failures: r.Failed(),
+	}
+	for _, f := range r.Files {
+		suite.Time += f.Duration
+		c := junitCase{
+			Name:      f.Input,
+			ClassName: "sass",
+			Time:      f.Duration,
+			SystemOut: strings.Join(f.Warnings, "\n"),
+		}
+		if f.Error != nil {
+			c.Failure = &junitFailure{
+				Message: f.Error.Message,
+				Body:    f.Error.String(),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,86 @@
+package wellington
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	libsass "github.com/wellington/go-libsass"
+	"github.com/wellington/wellington/handlers"
+)
+
+func TestBuild_Report(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.scss": "@warn \"deprecated\";\ndiv { color: red; }",
+		"b.scss": "div {",
+	}
+	for name, contents := range files {
+		err := ioutil.WriteFile(filepath.Join(sdir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	libsass.RegisterSassFunc("@warn", handlers.Warn)
+	bdir := filepath.Join(tdir, "build")
+	args := &BuildArgs{BuildDir: bdir, KeepGoing: true}
+	args.WithPaths([]string{sdir})
+	b := NewBuild(args, NewPartialMap())
+	if err := b.Run(); err == nil {
+		t.Fatal("no error reported")
+	}
+
+	r := b.Report()
+	if e := 2; len(r.Files) != e {
+		t.Fatalf("got: %d wanted: %d", len(r.Files), e)
+	}
+	a := r.Files[0]
+	if e := filepath.Join(bdir, "a.css"); a.Output != e {
+		t.Errorf("got: %s wanted: %s", a.Output, e)
+	}
+	if a.Bytes == 0 {
+		t.Error("no bytes reported")
+	}
+	if len(a.Warnings) != 1 || a.Warnings[0] != "deprecated" {
+		t.Errorf("got: %q wanted: [deprecated]", a.Warnings)
+	}
+	if a.Error != nil {
+		t.Errorf("unexpected error: %s", a.Error)
+	}
+	if r.Files[1].Error == nil || r.Files[1].Error.Line != 1 {
+		t.Errorf("got: %+v wanted: error on line 1", r.Files[1].Error)
+	}
+	if e := 1; r.Failed() != e {
+		t.Errorf("got: %d wanted: %d", r.Failed(), e)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var suite junitSuite
+	if err := xml.Unmarshal(buf.Bytes(), &suite); err != nil {
+		t.Fatal(err)
+	}
+	if suite.Tests != 2 || suite.Failures != 1 {
+		t.Errorf("got: %d tests %d failures wanted: 2 tests 1 failure",
+			suite.Tests, suite.Failures)
+	}
+	if !strings.Contains(buf.String(), "<failure") {
+		t.Errorf("no failure in:\n%s", buf.String())
+	}
+}
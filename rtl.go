@@ -0,0 +1,348 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// NoRTL is the comment directive leaving a rule or declaration as it is
+// in the RTL copy ie. /* wt:nortl */. Compressed output only keeps
+// comments starting with /*!.
+const NoRTL = directivePrefix + "nortl"
+
+// rtlPath returns the RTL copy of the CSS at name ie. main.rtl.css
+func rtlPath(name string) string {
+	return strings.TrimSuffix(name, ".css") + ".rtl.css"
+}
+
+// rtlOutput writes the RTL copy of the CSS built from path, see
+// BuildArgs.RTL. The copy has no source map. The name written is
+// returned, or nothing without RTL.
+func (b *BuildArgs) rtlOutput(path string) (string, error) {
+	if !b.RTL || len(b.BuildDir) == 0 {
+		return "", nil
+	}
+	name := b.outPath(path)
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	css := sourceMapURL.ReplaceAllString(string(bs), "")
+	out := rtlPath(name)
+	return out, writeFile(out, []byte(flipCSS(css)))
+}
+
+// flipCSS mirrors css for right to left languages. Left and right are
+// swapped in properties and values, statements marked with NoRTL are
+// left alone.
+func flipCSS(css string) string {
+	var buf strings.Builder
+	for _, st := range statements(css) {
+		buf.WriteString(st.flip())
+	}
+	return buf.String()
+}
+
+// flip returns the mirrored text of the statement
+func (st statement) flip() string {
+	if !st.block {
+		if strings.Contains(st.text, NoRTL) {
+			return st.text
+		}
+		return flipDecl(st.text)
+	}
+	head := st.text[:len(st.text)-len(st.body)-1]
+	if strings.Contains(head, NoRTL) {
+		return st.text
+	}
+	if strings.HasPrefix(st.prelude, "@") {
+		name := strings.ToLower(strings.TrimPrefix(st.prelude, "@"))
+		// the sources of a font do not have a direction
+		if strings.HasPrefix(name, "font-face") || strings.HasPrefix(name, "page") {
+			return st.text
+		}
+	}
+	return head + flipCSS(st.body) + "}"
+}
+
+// flipDecl mirrors a declaration, the text between its colon and value
+// and any !important are kept
+func flipDecl(text string) string {
+	start := 0
+	for {
+		for start < len(text) && isSpace(text[start]) {
+			start++
+		}
+		if !strings.HasPrefix(text[start:], "/*") {
+			break
+		}
+		end := strings.Index(text[start+2:], "*/")
+		if end < 0 {
+			return text
+		}
+		start += end + 4
+	}
+	colon := strings.IndexByte(text[start:], ':')
+	if colon < 0 || strings.HasPrefix(text[start:], "--") {
+		return text
+	}
+	colon += start
+	prop := strings.TrimSpace(text[start:colon])
+
+	end := len(text)
+	for end > colon && (isSpace(text[end-1]) || text[end-1] == ';') {
+		end--
+	}
+	vstart := colon + 1
+	for vstart < end && isSpace(text[vstart]) {
+		vstart++
+	}
+	value := text[vstart:end]
+	if i := strings.LastIndex(value, "!"); i >= 0 {
+		end = vstart + i
+		for end > vstart && isSpace(text[end-1]) {
+			end--
+		}
+		value = text[vstart:end]
+	}
+
+	// the prefix is the text before the property
+	propStart := start + strings.Index(text[start:colon], prop)
+	return text[:propStart] + flipProperty(prop) + text[propStart+len(prop):vstart] +
+		flipValue(strings.ToLower(prop), value) + text[end:]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// flipProperty swaps left and right in a property ie. margin-left
+func flipProperty(prop string) string {
+	parts := strings.Split(prop, "-")
+	for i, part := range parts {
+		parts[i] = swapWord(part, "left", "right")
+	}
+	return strings.Join(parts, "-")
+}
+
+// swapWord returns b for a and a for b, ignoring case
+func swapWord(w, a, b string) string {
+	switch strings.ToLower(w) {
+	case a:
+		return b
+	case b:
+		return a
+	}
+	return w
+}
+
+var rtlCursors = map[string]string{
+	"e-resize": "w-resize", "w-resize": "e-resize",
+	"ne-resize": "nw-resize", "nw-resize": "ne-resize",
+	"se-resize": "sw-resize", "sw-resize": "se-resize",
+	"nesw-resize": "nwse-resize", "nwse-resize": "nesw-resize",
+}
+
+// flipValue mirrors the value of prop
+func flipValue(prop, value string) string {
+	// vendor prefixes do not change the meaning of a property
+	if strings.HasPrefix(prop, "-") {
+		if i := strings.IndexByte(prop[1:], '-'); i >= 0 {
+			prop = prop[i+2:]
+		}
+	}
+	switch prop {
+	case "float", "clear", "text-align", "text-align-last", "caption-side",
+		"transform-origin", "perspective-origin", "object-position":
+		return mapWords(value, func(w string) string {
+			return swapWord(w, "left", "right")
+		})
+	case "direction":
+		return mapWords(value, func(w string) string {
+			return swapWord(w, "ltr", "rtl")
+		})
+	case "cursor":
+		return mapWords(value, func(w string) string {
+			if c, ok := rtlCursors[strings.ToLower(w)]; ok {
+				return c
+			}
+			return w
+		})
+	case "margin", "padding", "border-width", "border-color", "border-style",
+		"inset", "scroll-margin", "scroll-padding":
+		fields := splitTop(value, ' ')
+		if len(fields) == 4 {
+			fields[1], fields[3] = fields[3], fields[1]
+		}
+		return strings.Join(fields, " ")
+	case "border-radius":
+		corners := splitTop(value, '/')
+		for i, c := range corners {
+			corners[i] = flipCorners(strings.TrimSpace(c))
+		}
+		return strings.Join(corners, " / ")
+	case "box-shadow", "text-shadow":
+		return mapLayers(value, flipShadow)
+	case "background", "background-position", "background-position-x",
+		"mask", "mask-position":
+		return mapLayers(value, flipPosition)
+	}
+	return value
+}
+
+// flipCorners mirrors the radii of border-radius, top-left top-right
+// bottom-right bottom-left
+func flipCorners(value string) string {
+	f := splitTop(value, ' ')
+	switch len(f) {
+	case 2:
+		f[0], f[1] = f[1], f[0]
+	case 3:
+		f = []string{f[1], f[0], f[1], f[2]}
+	case 4:
+		f[0], f[1], f[2], f[3] = f[1], f[0], f[3], f[2]
+	}
+	return strings.Join(f, " ")
+}
+
+// flipShadow negates the horizontal offset of a shadow
+func flipShadow(layer string) string {
+	f := splitTop(layer, ' ')
+	for i, w := range f {
+		if !isLength(w) {
+			continue
+		}
+		f[i] = negate(w)
+		break
+	}
+	return strings.Join(f, " ")
+}
+
+// flipPosition swaps left and right in a background position, a
+// percentage as the horizontal position is measured from the other
+// side. Lengths, like the offsets of sprites, select part of the image
+// and are kept.
+func flipPosition(layer string) string {
+	f := splitTop(layer, ' ')
+	first := true
+	for i, w := range f {
+		// background-size follows a slash
+		size := ""
+		if j := strings.IndexByte(w, '/'); j >= 0 {
+			w, size = w[:j], w[j:]
+		}
+		switch {
+		case strings.EqualFold(w, "left") || strings.EqualFold(w, "right"):
+			w = swapWord(w, "left", "right")
+			first = false
+		case strings.EqualFold(w, "top") || strings.EqualFold(w, "bottom") ||
+			strings.EqualFold(w, "center"):
+			first = false
+		case isLength(w):
+			if first && strings.HasSuffix(w, "%") {
+				p, err := strconv.ParseFloat(strings.TrimSuffix(w, "%"), 64)
+				if err == nil {
+					w = strconv.FormatFloat(100-p, 'f', -1, 64) + "%"
+				}
+			}
+			first = false
+		}
+		f[i] = w + size
+		if len(size) > 0 {
+			break
+		}
+	}
+	return strings.Join(f, " ")
+}
+
+// isLength reports whether w is a number with an optional unit
+func isLength(w string) bool {
+	if len(w) == 0 {
+		return false
+	}
+	c := w[0]
+	if c == '-' || c == '+' {
+		if len(w) == 1 {
+			return false
+		}
+		c = w[1]
+	}
+	return c >= '0' && c <= '9' || c == '.'
+}
+
+// negate flips the sign of a length, zero is kept as it is
+func negate(w string) string {
+	if strings.HasPrefix(w, "-") {
+		return w[1:]
+	}
+	w = strings.TrimPrefix(w, "+")
+	num := strings.TrimRight(w, "abcdefghijklmnopqrstuvwxyz%")
+	if n, err := strconv.ParseFloat(num, 64); err == nil && n == 0 {
+		return w
+	}
+	return "-" + w
+}
+
+// mapLayers applies f to each comma separated layer of a value
+func mapLayers(value string, f func(string) string) string {
+	layers := splitTop(value, ',')
+	for i, layer := range layers {
+		trimmed := strings.TrimSpace(layer)
+		layers[i] = strings.Replace(layer, trimmed, f(trimmed), 1)
+	}
+	return strings.Join(layers, ",")
+}
+
+// mapWords applies f to each word of value outside of functions and
+// strings
+func mapWords(value string, f func(string) string) string {
+	var buf strings.Builder
+	for _, w := range splitTop(value, ' ') {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		if strings.ContainsAny(w, "(\"'") {
+			buf.WriteString(w)
+			continue
+		}
+		var parts []string
+		for _, p := range strings.Split(w, ",") {
+			parts = append(parts, f(p))
+		}
+		buf.WriteString(strings.Join(parts, ","))
+	}
+	return buf.String()
+}
+
+// splitTop splits s at sep outside of functions and strings, runs of
+// spaces are a single separator
+func splitTop(s string, sep byte) []string {
+	var parts []string
+	start, paren := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\'':
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case c == '(':
+			paren++
+		case c == ')':
+			if paren > 0 {
+				paren--
+			}
+		case paren == 0 && (c == sep || sep == ' ' && isSpace(c)):
+			if sep != ' ' || i > start {
+				parts = append(parts, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if sep != ' ' || start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
@@ -0,0 +1,136 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlipCSS(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"a { margin-left: 1px; padding-right: 2px !important; }",
+			"a { margin-right: 1px; padding-left: 2px !important; }"},
+		{"a { left: 0; border-top-left-radius: 2px; }",
+			"a { right: 0; border-top-right-radius: 2px; }"},
+		{"a { float: left; text-align: right; clear: both; }",
+			"a { float: right; text-align: left; clear: both; }"},
+		{"a { direction: ltr; cursor: ne-resize; }",
+			"a { direction: rtl; cursor: nw-resize; }"},
+		{"a { margin: 1px 2px 3px 4px; padding: 1px 2px; }",
+			"a { margin: 1px 4px 3px 2px; padding: 1px 2px; }"},
+		{"a { border-radius: 1px 2px 3px 4px / 5px 6px; }",
+			"a { border-radius: 2px 1px 4px 3px / 6px 5px; }"},
+		{"a { box-shadow: inset 2px 1px red, -1px 0 rgba(0, 0, 0, 0.5); }",
+			"a { box-shadow: inset -2px 1px red, 1px 0 rgba(0, 0, 0, 0.5); }"},
+		{"a { background-position: 20% 50%, left top; }",
+			"a { background-position: 80% 50%, right top; }"},
+		// sprites select part of the image with lengths
+		{`a { background: url("s.png") -10px -20px no-repeat; }`,
+			`a { background: url("s.png") -10px -20px no-repeat; }`},
+		{`a { background: url("left.png") right 5px top/cover; }`,
+			`a { background: url("left.png") left 5px top/cover; }`},
+		{"@media print { a { left: 0; } }\n@font-face { src: url(left.woff); }",
+			"@media print { a { right: 0; } }\n@font-face { src: url(left.woff); }"},
+		// directives
+		{"/* wt:nortl */\na { left: 0; }\nb { left: 0; }",
+			"/* wt:nortl */\na { left: 0; }\nb { right: 0; }"},
+		{"a { /*! wt:nortl */ left: 0; right: 1px; }",
+			"a { /*! wt:nortl */ left: 0; left: 1px; }"},
+		{"a{--left:0;float:left}", "a{--left:0;float:right}"},
+	}
+	for _, test := range tests {
+		if got := flipCSS(test.in); got != test.out {
+			t.Errorf("got: %q\nwanted: %q", got, test.out)
+		}
+	}
+}
+
+func TestBuild_rtl(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_rtl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(sdir, "a.scss"), []byte(`$map: sprite-map("*.png");
+div { float: left; background: sprite($map, "140"); }
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bdir := filepath.Join(tdir, "build")
+	args := &BuildArgs{
+		BuildDir:  bdir,
+		ImageDir:  "test/img",
+		Gen:       filepath.Join(tdir, "gen"),
+		SourceMap: true,
+		Manifest:  filepath.Join(bdir, "manifest.json"),
+		Prune:     true,
+		RTL:       true,
+	}
+	args.WithPaths([]string{sdir})
+	b := NewBuild(args, NewPartialMap())
+	plan, err := b.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtl := filepath.Join(bdir, "a.rtl.css")
+	if plan[0].RTL != rtl {
+		t.Errorf("got: %s wanted: %s", plan[0].RTL, rtl)
+	}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if fr := b.Report().Files[0]; fr.RTL != rtl {
+		t.Errorf("got: %s wanted: %s", fr.RTL, rtl)
+	}
+
+	ltr, err := ioutil.ReadFile(filepath.Join(bdir, "a.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(rtl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	css := string(bs)
+	if !strings.Contains(css, "float: right;") {
+		t.Errorf("float was not flipped: %q", css)
+	}
+	if strings.Contains(css, "sourceMappingURL") {
+		t.Errorf("RTL copy links the source map: %q", css)
+	}
+	// the sprite shows the same image
+	i := strings.Index(string(ltr), "background:")
+	line := string(ltr)[i:]
+	line = line[:strings.IndexByte(line, ';')]
+	if !strings.Contains(css, line) {
+		t.Errorf("got: %q wanted: %q", css, line)
+	}
+
+	// The copy is kept by Prune and hashed with the CSS
+	if len(b.Pruned()) > 0 {
+		t.Errorf("pruned: %v", b.Pruned())
+	}
+	args.CacheBust = "filename"
+	b = NewBuild(args, NewPartialMap())
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	fr := b.Report().Files[0]
+	if !strings.HasPrefix(filepath.Base(fr.RTL), "a.rtl.") || fr.RTL == rtl {
+		t.Errorf("got: %s wanted a hashed copy", fr.RTL)
+	}
+	if _, err := os.Stat(fr.RTL); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,232 @@
+package wellington
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxSelectors is the number of selectors Internet Explorer 9
+// and older read from a stylesheet, the rest are ignored
+const DefaultMaxSelectors = 4095
+
+var (
+	// sourceMapURL matches the comment linking CSS to its source map
+	sourceMapURL = regexp.MustCompile(`/\*# sourceMappingURL=[^*]*\*/\s*$`)
+	cssComment   = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+// groupingRules are the at-rules containing rules whose selectors count
+// towards the limit
+var groupingRules = map[string]bool{
+	"media":     true,
+	"supports":  true,
+	"document":  true,
+	"layer":     true,
+	"container": true,
+}
+
+// statement is a top level statement or block of a stylesheet
+type statement struct {
+	// text includes the whitespace and comments before the statement
+	text string
+	// prelude is the selector or at-rule without comments
+	prelude string
+	body    string
+	block   bool
+}
+
+// statements splits css into the statements at its top level
+func statements(css string) []statement {
+	var sts []statement
+	start, bodyStart, depth, paren := 0, 0, 0, 0
+	for i := 0; i < len(css); i++ {
+		switch c := css[i]; {
+		case c == '"' || c == '\'':
+			for i++; i < len(css) && css[i] != c; i++ {
+				if css[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				i = len(css)
+				break
+			}
+			i += end + 3
+		case c == '(':
+			paren++
+		case c == ')':
+			if paren > 0 {
+				paren--
+			}
+		case paren > 0:
+		case c == '{':
+			if depth == 0 {
+				bodyStart = i + 1
+			}
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+			if depth > 0 {
+				break
+			}
+			sts = append(sts, statement{
+				text:    css[start : i+1],
+				prelude: cleanPrelude(css[start : bodyStart-1]),
+				body:    css[bodyStart:i],
+				block:   true,
+			})
+			start = i + 1
+		case c == ';' && depth == 0:
+			sts = append(sts, statement{
+				text:    css[start : i+1],
+				prelude: cleanPrelude(css[start:i]),
+			})
+			start = i + 1
+		}
+	}
+	if start >= len(css) {
+		return sts
+	}
+	rest := css[start:]
+	return append(sts, statement{text: rest, prelude: cleanPrelude(rest)})
+}
+
+func cleanPrelude(s string) string {
+	return strings.TrimSpace(cssComment.ReplaceAllString(s, ""))
+}
+
+// selectors counts the selectors in a statement, at-rules like
+// @font-face and @keyframes have none
+func (st statement) selectors() int {
+	if !st.block {
+		return 0
+	}
+	if strings.HasPrefix(st.prelude, "@") {
+		name := strings.ToLower(strings.TrimPrefix(st.prelude, "@"))
+		if i := strings.IndexAny(name, " \t\n({"); i >= 0 {
+			name = name[:i]
+		}
+		if !groupingRules[name] {
+			return 0
+		}
+		n := 0
+		for _, inner := range statements(st.body) {
+			n += inner.selectors()
+		}
+		return n
+	}
+	return countList(st.prelude)
+}
+
+// countList counts the comma separated items in a selector list
+func countList(s string) int {
+	n, paren := 1, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\'':
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case c == '(' || c == '[':
+			paren++
+		case c == ')' || c == ']':
+			if paren > 0 {
+				paren--
+			}
+		case c == ',' && paren == 0:
+			n++
+		}
+	}
+	return n
+}
+
+// splitCSS splits css into parts of at most max selectors. Blocks are
+// never divided, a block over max is a part of its own. A @charset is
+// returned separately to be repeated at the start of each part.
+func splitCSS(css string, max int) (string, []string) {
+	var charset string
+	var parts []string
+	var cur strings.Builder
+	n := 0
+	for _, st := range statements(css) {
+		if len(charset) == 0 && cur.Len() == 0 &&
+			strings.HasPrefix(st.prelude, "@charset") {
+			charset = strings.TrimSpace(st.text) + "\n"
+			continue
+		}
+		count := st.selectors()
+		if n > 0 && n+count > max {
+			parts = append(parts, cur.String())
+			cur.Reset()
+			n = 0
+		}
+		text := st.text
+		if cur.Len() == 0 {
+			text = strings.TrimLeft(text, " \t\r\n")
+		}
+		cur.WriteString(text)
+		n += count
+	}
+	if cur.Len() > 0 || len(parts) == 0 {
+		parts = append(parts, cur.String())
+	}
+	for i, part := range parts {
+		parts[i] = strings.TrimRight(part, " \t\r\n") + "\n"
+	}
+	return charset, parts
+}
+
+// splitFile splits the CSS at name when it has more than MaxSelectors
+// selectors. All but the last part are written beside it as
+// name-1.css, name-2.css... and imported by the CSS, which keeps the
+// last part. Split CSS has no source map, as its rules moved between
+// files. The parts written are returned.
+func (b *BuildArgs) splitFile(name string) ([]string, error) {
+	if b.MaxSelectors <= 0 || len(b.BuildDir) == 0 {
+		return nil, nil
+	}
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	css := sourceMapURL.ReplaceAllString(string(bs), "")
+	charset, parts := splitCSS(css, b.MaxSelectors)
+	if len(parts) < 2 {
+		return nil, nil
+	}
+
+	ext := filepath.Ext(name)
+	var written []string
+	var buf bytes.Buffer
+	buf.WriteString(charset)
+	for i, part := range parts[:len(parts)-1] {
+		pname := fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i+1, ext)
+		if err := writeFile(pname, []byte(charset+part)); err != nil {
+			return written, err
+		}
+		if b.CacheBust == "filename" {
+			if pname, err = hashName(pname); err != nil {
+				return written, err
+			}
+		}
+		written = append(written, pname)
+		fmt.Fprintf(&buf, "@import url(%q);\n", filepath.Base(pname))
+	}
+	buf.WriteString(parts[len(parts)-1])
+	if err := writeFile(name, buf.Bytes()); err != nil {
+		return written, err
+	}
+	if err := os.Remove(name + ".map"); err != nil && !os.IsNotExist(err) {
+		return written, err
+	}
+	return written, nil
+}
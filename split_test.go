@@ -0,0 +1,129 @@
+package wellington
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStatement_selectors(t *testing.T) {
+	css := `/* a, b { } */
+a, b { color: red; }
+a:is(b, c), [title="d,e"] { color: red; }
+@media print { a, b { color: red; } @supports (x: y) { c { color: red; } } }
+@font-face { font-family: f; }
+@keyframes k { from { color: red; } to { color: blue; } }
+@import "x.css";`
+	var got []int
+	for _, st := range statements(css) {
+		got = append(got, st.selectors())
+	}
+	if e := []int{2, 2, 3, 0, 0, 0}; !reflect.DeepEqual(got, e) {
+		t.Errorf("got: %v wanted: %v", got, e)
+	}
+}
+
+func TestSplitCSS(t *testing.T) {
+	css := "@charset \"UTF-8\";\na { color: red; }\nb, c { color: red; }\n" +
+		"@media print {\n  d { color: red; } }\ne { content: \"}\"; }"
+	charset, parts := splitCSS(css, 2)
+	if e := "@charset \"UTF-8\";\n"; charset != e {
+		t.Errorf("got: %q wanted: %q", charset, e)
+	}
+	e := []string{
+		"a { color: red; }\n",
+		"b, c { color: red; }\n",
+		"@media print {\n  d { color: red; } }\ne { content: \"}\"; }\n",
+	}
+	if !reflect.DeepEqual(parts, e) {
+		t.Errorf("got: %q\nwanted: %q", parts, e)
+	}
+
+	// A block over the limit is kept whole
+	_, parts = splitCSS("a, b, c { color: red; }\n", 2)
+	if len(parts) != 1 {
+		t.Errorf("got: %q wanted one part", parts)
+	}
+}
+
+func TestBuild_maxSelectors(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testbuild_maxselectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	sdir := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(sdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(sdir, "a.scss"), []byte(
+		"@for $i from 1 through 5 { .c#{$i} { color: red; } }\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bdir := filepath.Join(tdir, "build")
+	args := &BuildArgs{
+		BuildDir:     bdir,
+		SourceMap:    true,
+		Manifest:     filepath.Join(bdir, "manifest.json"),
+		Prune:        true,
+		MaxSelectors: 2,
+	}
+	args.WithPaths([]string{sdir})
+	b := NewBuild(args, NewPartialMap())
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []string{
+		filepath.Join(bdir, "a-1.css"),
+		filepath.Join(bdir, "a-2.css"),
+	}
+	if fr := b.Report().Files[0]; !reflect.DeepEqual(fr.Parts, parts) {
+		t.Errorf("got: %v wanted: %v", fr.Parts, parts)
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(bdir, "a.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := "@import url(\"a-1.css\");\n@import url(\"a-2.css\");\n.c5 {\n  color: red; }\n"
+	if string(bs) != e {
+		t.Errorf("got: %q wanted: %q", bs, e)
+	}
+	bs, err = ioutil.ReadFile(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := ".c3 {\n  color: red; }\n\n.c4 {\n  color: red; }\n"; string(bs) != e {
+		t.Errorf("got: %q wanted: %q", bs, e)
+	}
+	if _, err := os.Stat(filepath.Join(bdir, "a.css.map")); !os.IsNotExist(err) {
+		t.Errorf("source map of split CSS was kept: %v", err)
+	}
+	if len(b.Pruned()) > 0 {
+		t.Errorf("parts were pruned: %v", b.Pruned())
+	}
+
+	// Parts are hashed with the CSS
+	args.CacheBust = "filename"
+	b = NewBuild(args, NewPartialMap())
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	fr := b.Report().Files[0]
+	if len(fr.Parts) != 2 || !strings.HasPrefix(filepath.Base(fr.Parts[0]), "a-1.") {
+		t.Fatalf("got: %v wanted hashed parts", fr.Parts)
+	}
+	bs, err = ioutil.ReadFile(fr.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := filepath.Base(fr.Parts[0]); !strings.Contains(string(bs), e) {
+		t.Errorf("got: %q wanted an import of %s", bs, e)
+	}
+}
@@ -0,0 +1,101 @@
+package wellington
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/wellington/wellington/payload"
+)
+
+// Target is a named set of sources and directories in a config. All
+// targets are built together, any setting left empty is inherited
+// from the config.
+type Target struct {
+	Paths    []string `json:"paths" yaml:"paths"`
+	Project  string   `json:"proj" yaml:"proj"`
+	BuildDir string   `json:"build" yaml:"build"`
+	ImageDir string   `json:"dir" yaml:"dir"`
+	Font     string   `json:"font" yaml:"font"`
+	Gen      string   `json:"gen" yaml:"gen"`
+	Includes []string `json:"includes" yaml:"includes"`
+	Manifest string   `json:"manifest" yaml:"manifest"`
+
+	name string
+}
+
+// Name returns the name of the target in the config
+func (t *Target) Name() string {
+	return t.name
+}
+
+// SelectTargets returns the named targets sorted by name. If no names
+// are passed, every target in the config is returned.
+func (c *Config) SelectTargets(names []string) ([]*Target, error) {
+	if len(names) == 0 {
+		for name := range c.Targets {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	targets := make([]*Target, 0, len(names))
+	for _, name := range names {
+		t, ok := c.Targets[name]
+		if !ok || t == nil {
+			return nil, fmt.Errorf("target %s not found", name)
+		}
+		t.name = name
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// BuildArgs returns a copy of base with the target's sources and
+// directories applied. The copy does not share base's payload.
+func (t *Target) BuildArgs(base *BuildArgs) *BuildArgs {
+	gba := *base
+	gba.Payload = nil
+	if len(t.BuildDir) > 0 {
+		gba.BuildDir = t.BuildDir
+	}
+	if len(t.ImageDir) > 0 {
+		gba.ImageDir = t.ImageDir
+	}
+	if len(t.Font) > 0 {
+		gba.Font = t.Font
+	}
+	if len(t.Gen) > 0 {
+		gba.Gen = t.Gen
+	}
+	if len(t.Manifest) > 0 {
+		gba.Manifest = t.Manifest
+	}
+	if len(t.Project) > 0 {
+		gba.Project = t.Project
+	}
+
+	var incs []string
+	incs = append(incs, base.Includes...)
+	incs = append(incs, t.Includes...)
+	gba.Includes = append(incs, t.Paths...)
+	gba.WithPaths(append([]string{}, t.Paths...))
+	return &gba
+}
+
+// SharePayloads gives BuildArgs with the same image and generated
+// image directories a single payload, so sprites used by more than one
+// of them are only generated once.
+func SharePayloads(args ...*BuildArgs) {
+	shared := make(map[[2]string]*BuildArgs)
+	for _, gba := range args {
+		key := [2]string{gba.ImageDir, gba.Gen}
+		if first, ok := shared[key]; ok {
+			gba.Payload = first.Payload
+			continue
+		}
+		if gba.Payload == nil {
+			gba.Payload = payload.New()
+		}
+		shared[key] = gba
+	}
+}
@@ -0,0 +1,98 @@
+package wellington
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConfig_SelectTargets(t *testing.T) {
+	in := bytes.NewBufferString(`
+dir: img
+targets:
+  web:
+    paths: [web/sass]
+    build: web/css
+  admin:
+    paths: [admin/sass]
+    build: admin/css
+    dir: admin/img
+`)
+	cfg, err := ReadConfig(in, ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := cfg.SelectTargets(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := 2; len(targets) != e {
+		t.Fatalf("got: %d wanted: %d", len(targets), e)
+	}
+	if e := "admin"; targets[0].Name() != e {
+		t.Errorf("got: %s wanted: %s", targets[0].Name(), e)
+	}
+
+	targets, err = cfg.SelectTargets([]string{"web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := 1; len(targets) != e {
+		t.Fatalf("got: %d wanted: %d", len(targets), e)
+	}
+
+	if _, err := cfg.SelectTargets([]string{"mobile"}); err == nil {
+		t.Error("no error for missing target")
+	}
+}
+
+func TestTarget_BuildArgs(t *testing.T) {
+	base := &BuildArgs{
+		ImageDir: "img",
+		Gen:      "gen",
+		BuildDir: "build",
+		Includes: []string{"vendor"},
+		Project:  "proj",
+		Style:    2,
+	}
+	web := &Target{Paths: []string{"web"}, BuildDir: "web/css", Project: "web"}
+	admin := &Target{Paths: []string{"admin"}}
+	other := &Target{Paths: []string{"other"}, ImageDir: "other/img"}
+
+	args := []*BuildArgs{
+		web.BuildArgs(base),
+		admin.BuildArgs(base),
+		other.BuildArgs(base),
+	}
+
+	if e := "web/css"; args[0].BuildDir != e {
+		t.Errorf("got: %s wanted: %s", args[0].BuildDir, e)
+	}
+	if e := "build"; args[1].BuildDir != e {
+		t.Errorf("got: %s wanted: %s", args[1].BuildDir, e)
+	}
+	if e := "web"; args[0].Project != e {
+		t.Errorf("got: %s wanted: %s", args[0].Project, e)
+	}
+	// Project is inherited like the other settings
+	if e := "proj"; args[1].Project != e {
+		t.Errorf("got: %s wanted: %s", args[1].Project, e)
+	}
+	if args[1].Style != base.Style {
+		t.Errorf("got: %d wanted: %d", args[1].Style, base.Style)
+	}
+	if e := 2; len(args[0].Includes) != e {
+		t.Errorf("got: %d wanted: %d", len(args[0].Includes), e)
+	}
+	if e := 1; len(base.Includes) != e {
+		t.Errorf("base modified got: %d wanted: %d", len(base.Includes), e)
+	}
+
+	SharePayloads(args...)
+	if args[0].Payload == nil || args[0].Payload != args[1].Payload {
+		t.Error("targets with the same image directories should share a payload")
+	}
+	if args[2].Payload == args[0].Payload {
+		t.Error("targets with different image directories share a payload")
+	}
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -13,19 +14,18 @@ import (
 	"path/filepath"
 	"runtime/pprof"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	libsass "github.com/wellington/go-libsass"
-	"github.com/wellington/spritewell"
 	"github.com/wellington/wellington/payload"
 	"github.com/wellington/wellington/version"
 
 	wt "github.com/wellington/wellington"
 	_ "github.com/wellington/wellington/handlers"
+	_ "github.com/wellington/wellington/processors"
 )
 
 var (
@@ -40,9 +40,33 @@ var (
 	httpPath                      string
 	timeB                         bool
 	config                        string
+	env                           string
+	targetNames                   []string
 	debug                         bool
 	cachebust                     string
 	sourceMap                     bool
+	manifest                      string
+	cacheDir                      string
+	jobs                          int
+	progress                      bool
+	keepGoing                     bool
+	report, reportFile            string
+	prune                         bool
+	include, exclude              []string
+	dryRun                        string
+	compress                      []string
+	processors                    []string
+	browsers                      string
+	maxSelectors                  int
+	rtl                           bool
+	liveReload                    string
+	debounce                      time.Duration
+	// Sass variables declared by the config file
+	header string
+	// per file options declared by the config file
+	overrides []wt.Override
+	// targets selected from the config file
+	targets []*wt.Target
 
 	// unused
 	relativeAssets bool
@@ -92,22 +116,45 @@ func flags(set *pflag.FlagSet) {
 	set.BoolVar(&relativeAssets, "relative-assets", false, "UNSUPPORTED: Make compass asset helpers generate relative urls to assets.")
 
 	set.BoolVar(&sourceMap, "source-map", false, "Enable emitting of source maps, must specify build directory to use this")
+	set.StringVar(&cacheDir, "cache", "", "Directory to keep an incremental build cache in ie. .wt-cache, unchanged files are not compiled again")
+	set.StringVar(&manifest, "manifest", "", "Write a JSON manifest of the built files and sprites to this path")
 	set.BoolVarP(&showVersion, "version", "v", false, "Show the app version")
-	set.StringVar(&cachebust, "cachebust", "", "Defeat cache by appending timestamps to static assets ie. ts, sum, timestamp")
+	set.StringVar(&cachebust, "cachebust", "", "Defeat cache by appending timestamps to static assets ie. ts, sum, timestamp or filename to write content hashed copies")
 	set.StringVarP(&style, "style", "s", "nested",
 		`nested style of output CSS
                         available options: nested, expanded, compact, compressed`)
 	set.StringVar(&style, "output-style", "nested", "")
 	set.MarkDeprecated("output-style", "Use --style instead")
 	set.BoolVar(&timeB, "time", false, "Retrieve timing information")
+	set.IntVarP(&jobs, "jobs", "j", 0, "Number of files to compile at once, defaults to the number of CPUs")
+	set.BoolVarP(&keepGoing, "keep-going", "k", false, "Build every file after a failure and report all of the failures at the end")
+	set.StringVar(&report, "report", "", "Write a report of every file built ie. json, junit")
+	set.StringVar(&reportFile, "report-file", "", "Path to write the report to, defaults to stderr")
+	set.StringSliceVar(&compress, "compress", nil, "Write compressed copies of the CSS and source maps for static file servers ie. gzip,brotli")
+	set.StringSliceVar(&processors, "processors", nil, fmt.Sprintf("Pass the CSS through these post processors in order, available: %s", strings.Join(wt.ProcessorNames(), ", ")))
+	set.StringVar(&browsers, "browsers", "", "Browsers autoprefixer adds prefixes for ie. \"last 2 versions, ie >= 11\", setting it enables autoprefixer")
+	set.BoolVar(&prune, "prune", false, "Remove CSS, source maps and sprites recorded by the last build's cache or manifest that the build did not produce")
+	set.BoolVar(&progress, "progress", false, "Report each file as it is compiled, in build order")
+	set.StringVar(&dryRun, "dry-run", "", "List the files that would be built and their outputs without writing anything, --dry-run=json for JSON")
+	set.Lookup("dry-run").NoOptDefVal = "text"
+	set.IntVar(&maxSelectors, "max-selectors", 0, fmt.Sprintf("Split CSS with more selectors into parts imported by the CSS, %d without a value", wt.DefaultMaxSelectors))
+	set.Lookup("max-selectors").NoOptDefVal = fmt.Sprint(wt.DefaultMaxSelectors)
+	set.StringVar(&liveReload, "livereload", "", fmt.Sprintf("Address of a server telling browsers about CSS rebuilt by watch, %s without a value. Pages include /livereload.js from it", wt.DefaultLiveReloadAddr))
+	set.Lookup("livereload").NoOptDefVal = wt.DefaultLiveReloadAddr
+	set.DurationVar(&debounce, "debounce", wt.DefaultDebounce, "Time watch waits for more changes before rebuilding, the files affected by all of them are rebuilt once")
+	set.BoolVar(&rtl, "rtl", false, "Also write a mirrored copy of each CSS file for right to left languages ie. main.rtl.css")
 
 	var nothing string
 	set.StringVar(&nothing, "require", "", "")
 	set.MarkDeprecated("require", "Compass backwards compat, Not supported")
 	set.MarkDeprecated("require", "Not supported")
-	set.StringVar(&nothing, "environment", "", "")
-	set.MarkDeprecated("environment", "Not supported")
+	set.StringSliceVar(&targetNames, "target", nil, "Build only the named targets from the config file")
+	set.StringVar(&env, "env", "", "Named environment from the config file to build with ie. development, production")
+	set.StringVar(&env, "environment", "", "")
+	set.MarkDeprecated("environment", "Use --env instead")
 	set.StringSliceVar(&includes, "includes", nil, "Include Sass from additional directories")
+	set.StringSliceVar(&include, "include", nil, "Only build Sass files matching these glob patterns ie. 'pages/**'")
+	set.StringSliceVar(&exclude, "exclude", nil, "Skip Sass files and directories matching these glob patterns ie. node_modules,vendor/**, see also .wtignore")
 	set.StringSliceVarP(&includes, "", "I", nil, "")
 	set.MarkDeprecated("I", "Compass backwards compat, use --includes instead")
 	set.StringVar(&buildDir, "css-dir", "",
@@ -116,7 +163,7 @@ func flags(set *pflag.FlagSet) {
 	set.StringVar(&jsDir, "javascripts-dir", "", "")
 	set.MarkDeprecated("javascripts-dir", "Compass backwards compat, ignored")
 	set.StringVarP(&config, "config", "c", "",
-		"Temporarily disabled: Location of the config file")
+		"Location of the config file, by default wt.yaml, wt.yml or wt.json is searched for in the working directory and its parents")
 
 	set.StringVar(&cpuprofile, "cpuprofile", "", "Go runtime cpu profilling for debugging")
 }
@@ -136,6 +183,13 @@ var watchCmd = &cobra.Command{
 	Run:   Watch,
 }
 
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Compile Sass and remove the CSS, source maps and sprites it no longer produces, requires --cache or --manifest",
+	Long:  ``,
+	Run:   Clean,
+}
+
 var httpCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Starts a http server that will convert Sass to CSS",
@@ -167,6 +221,7 @@ func AddCommands() {
 	wtCmd.AddCommand(httpCmd)
 	wtCmd.AddCommand(compileCmd)
 	wtCmd.AddCommand(watchCmd)
+	wtCmd.AddCommand(cleanCmd)
 }
 
 var wtCmd = &cobra.Command{
@@ -236,31 +291,213 @@ func parseBuildArgs(paths []string) *wt.BuildArgs {
 	if len(gen) > 0 {
 		gen = makeabs(wd, gen)
 	}
+	if len(manifest) > 0 {
+		manifest = makeabs(wd, manifest)
+	}
+	if len(cacheDir) > 0 {
+		cacheDir = makeabs(wd, cacheDir)
+	}
 	incs = append(incs, paths...)
 
 	gba := &wt.BuildArgs{
-		WorkDir:   wd,
-		ImageDir:  dir,
-		BuildDir:  buildDir,
-		Includes:  incs,
-		Font:      font,
-		Style:     style,
-		Gen:       gen,
-		Project:   proj,
-		Comments:  comments,
-		CacheBust: cachebust,
-		SourceMap: sourceMap,
+		WorkDir:    wd,
+		ImageDir:   dir,
+		BuildDir:   buildDir,
+		Includes:   incs,
+		Font:       font,
+		Style:      style,
+		Gen:        gen,
+		Project:    proj,
+		Comments:   comments,
+		CacheBust:  cachebust,
+		SourceMap:  sourceMap,
+		Manifest:   manifest,
+		Cache:      cacheDir,
+		Header:     header,
+		Jobs:       jobs,
+		KeepGoing:  keepGoing,
+		Prune:      prune,
+		Include:    include,
+		Exclude:    exclude,
+		Compress:   compress,
+		Processors: processorList(),
+		Browsers:   browsers,
+		Overrides:  overrides,
+
+		MaxSelectors: maxSelectors,
+		RTL:          rtl,
+	}
+	if progress {
+		gba.Progress = os.Stderr
 	}
 	gba.WithPaths(paths)
 	return gba
 }
 
-func globalRun(paths []string) (*wt.SafePartialMap, *wt.BuildArgs) {
+// processorList returns the processors, a browser query adds
+// autoprefixer when it is not listed
+func processorList() []string {
+	if len(browsers) == 0 {
+		return processors
+	}
+	for _, name := range processors {
+		if name == "autoprefixer" {
+			return processors
+		}
+	}
+	return append(append([]string{}, processors...), "autoprefixer")
+}
+
+// changed reports whether any of the named flags were set on the
+// command line. Single letter names also match flags by shorthand,
+// -I has no long name.
+func changed(set *pflag.FlagSet, names ...string) bool {
+	for _, name := range names {
+		if f := set.Lookup(name); f != nil && f.Changed {
+			return true
+		}
+		if len(name) == 1 {
+			if f := set.ShorthandLookup(name); f != nil && f.Changed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadConfig locates the project config file and applies its values to
+// every flag not set on the command line. Paths from the config are
+// used when none are passed as arguments.
+func loadConfig(set *pflag.FlagSet, paths []string) ([]string, error) {
+	path := config
+	if len(path) == 0 {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		path = wt.FindConfig(wd)
+		if len(path) == 0 {
+			if len(env) > 0 {
+				return nil, fmt.Errorf("environment %s requires a config file", env)
+			}
+			if len(targetNames) > 0 {
+				return nil, errors.New("targets require a config file")
+			}
+			return paths, nil
+		}
+	}
+
+	cfg, err := wt.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(env) > 0 {
+		if err := cfg.UseEnvironment(env); err != nil {
+			return nil, fmt.Errorf("config %s: %s", path, err)
+		}
+	}
+	header = cfg.SassHeader()
+	overrides = cfg.Overrides
+	if len(header) > 0 {
+		libsass.RegisterHeader(header)
+	}
+	if debug {
+		log.Printf("         Config: %s\n", path)
+	}
+	for _, warning := range cfg.Warnings {
+		log.Printf("config %s: %s\n", path, warning)
+	}
+
+	if !changed(set, "build", "css-dir") && len(cfg.BuildDir) > 0 {
+		buildDir = cfg.BuildDir
+	}
+	if !changed(set, "dir", "images-dir") && len(cfg.ImageDir) > 0 {
+		dir = cfg.ImageDir
+	}
+	if !changed(set, "font") && len(cfg.Font) > 0 {
+		font = cfg.Font
+	}
+	if !changed(set, "gen", "generated-images-path") && len(cfg.Gen) > 0 {
+		gen = cfg.Gen
+	}
+	if !changed(set, "includes", "I", "sass-dir") && len(cfg.Includes) > 0 {
+		includes = cfg.Includes
+	}
+	if !changed(set, "proj") && len(cfg.Project) > 0 {
+		proj = cfg.Project
+	}
+	if !changed(set, "style", "output-style") && len(cfg.Style) > 0 {
+		style = cfg.Style
+	}
+	if !changed(set, "comment") {
+		comments = cfg.Comments
+	}
+	if !changed(set, "cachebust") && len(cfg.CacheBust) > 0 {
+		cachebust = cfg.CacheBust
+	}
+	if !changed(set, "source-map") {
+		sourceMap = cfg.SourceMap
+	}
+	if !changed(set, "manifest") && len(cfg.Manifest) > 0 {
+		manifest = cfg.Manifest
+	}
+	if !changed(set, "include") && len(cfg.Include) > 0 {
+		include = cfg.Include
+	}
+	if !changed(set, "exclude") && len(cfg.Exclude) > 0 {
+		exclude = cfg.Exclude
+	}
+	if !changed(set, "compress") && len(cfg.Compress) > 0 {
+		compress = cfg.Compress
+	}
+	if !changed(set, "processors") && len(cfg.Processors) > 0 {
+		processors = cfg.Processors
+	}
+	if !changed(set, "browsers") && len(cfg.Browsers) > 0 {
+		browsers = cfg.Browsers
+	}
+	if !changed(set, "rtl") && cfg.RTL {
+		rtl = true
+	}
+	if !changed(set, "max-selectors") && cfg.MaxSelectors > 0 {
+		maxSelectors = cfg.MaxSelectors
+	}
+	if !changed(set, "jobs") && cfg.Jobs > 0 {
+		jobs = cfg.Jobs
+	}
+	if !changed(set, "cache") && len(cfg.Cache) > 0 {
+		cacheDir = cfg.Cache
+	}
+	if !changed(set, "httppath") && len(cfg.HTTPPath) > 0 {
+		httpPath = cfg.HTTPPath
+	}
+	if len(paths) > 0 {
+		return paths, nil
+	}
+	if len(cfg.Targets) > 0 {
+		targets, err = cfg.SelectTargets(targetNames)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %s", path, err)
+		}
+		return nil, nil
+	}
+	if len(targetNames) > 0 {
+		return nil, fmt.Errorf("config %s: no targets found", path)
+	}
+	return cfg.Paths, nil
+}
+
+func globalRun(cmd *cobra.Command, paths []string) (*wt.SafePartialMap, *wt.BuildArgs) {
 	// fmt.Printf("paths: %s args: % #v\n", paths, pflag.Args())
 	if argExit() {
 		return nil, nil
 	}
 
+	paths, err := loadConfig(cmd.Flags(), paths)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Profiling code
 	if cpuprofile != "" {
 		f, err := os.Create(cpuprofile)
@@ -279,18 +516,33 @@ func globalRun(paths []string) (*wt.SafePartialMap, *wt.BuildArgs) {
 		}()
 	}
 
+	if err := checkReport(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkDryRun(); err != nil {
+		log.Fatal(err)
+	}
+
 	for _, v := range paths {
 		if strings.HasPrefix(v, "-") {
 			log.Fatalf("Please specify flags before other arguments: %s", v)
 		}
 	}
 
-	if gen != "" {
+	// --dry-run writes nothing
+	if gen != "" && len(dryRun) == 0 {
 		err := os.MkdirAll(gen, 0755)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
+	for _, t := range targets {
+		if len(t.Gen) > 0 && len(dryRun) == 0 {
+			if err := os.MkdirAll(t.Gen, 0755); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
 
 	pMap := wt.NewPartialMap()
 	gba := parseBuildArgs(paths)
@@ -309,23 +561,46 @@ func globalRun(paths []string) (*wt.SafePartialMap, *wt.BuildArgs) {
 
 // Watch accepts a set of paths starting a recursive file watcher
 func Watch(cmd *cobra.Command, paths []string) {
-	pMap, gba := globalRun(paths)
-	bOpts := wt.NewBuild(gba, pMap)
-	err := bOpts.Run()
-	if err != nil {
-		log.Fatal(err)
+	pMap, gba := globalRun(cmd, paths)
+	if len(dryRun) > 0 {
+		if err := writePlans(gba); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	w, err := wt.NewWatcher(&wt.WatchOptions{
-		Paths:      paths,
-		BArgs:      gba,
-		PartialMap: pMap,
-	})
-	if err != nil {
-		log.Fatal("failed to start watcher: ", err)
+	var lr *wt.LiveReload
+	if len(liveReload) > 0 {
+		var err error
+		lr, err = serveLiveReload(liveReload)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
-	err = w.Watch()
-	if err != nil {
-		log.Fatal("filewatcher error: ", err)
+	if len(targets) > 0 {
+		err := watchTargets(gba, lr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		bOpts := wt.NewBuild(gba, pMap)
+		err := bOpts.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+		w, err := wt.NewWatcher(&wt.WatchOptions{
+			Paths:      gba.Paths(),
+			BArgs:      gba,
+			PartialMap: pMap,
+			LiveReload: lr,
+			Debounce:   debounce,
+		})
+		if err != nil {
+			log.Fatal("failed to start watcher: ", err)
+		}
+		err = w.Watch()
+		if err != nil {
+			log.Fatal("filewatcher error: ", err)
+		}
 	}
 
 	fmt.Println("File watcher started use `ctrl+d` to exit")
@@ -341,13 +616,33 @@ func Watch(cmd *cobra.Command, paths []string) {
 	}
 }
 
+// serveLiveReload starts the livereload server on addr
+func serveLiveReload(addr string) (*wt.LiveReload, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("livereload: %s", err)
+	}
+	lr := wt.NewLiveReload()
+	go func() {
+		if err := http.Serve(l, lr); err != nil {
+			log.Println("livereload:", err)
+		}
+	}()
+	host := l.Addr().String()
+	if strings.HasPrefix(addr, ":") {
+		host = "localhost" + addr
+	}
+	log.Printf("Live reload started, add to pages: <script src=\"http://%s/livereload.js\"></script>\n", host)
+	return lr, nil
+}
+
 // lis is exposed so test suite can shut it down
 var lis net.Listener
 
 // Serve starts a web server accepting POST calls and return CSS
 func Serve(cmd *cobra.Command, paths []string) {
 
-	_, gba := globalRun(paths)
+	_, gba := globalRun(cmd, paths)
 	if len(gba.Gen) == 0 {
 		log.Fatal("Must pass an image build directory to use HTTP")
 	}
@@ -372,18 +667,37 @@ func Serve(cmd *cobra.Command, paths []string) {
 // Compile handles compile files and stdin operations.
 func Compile(cmd *cobra.Command, paths []string) {
 	start := time.Now()
-	pMap, gba := globalRun(paths)
+	pMap, gba := globalRun(cmd, paths)
 	if gba == nil {
 		return
 	}
+	if len(dryRun) > 0 {
+		if err := writePlans(gba); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	defer func() {
 		log.Printf("Compilation took: %s\n", time.Since(start))
 	}()
 
+	if len(targets) > 0 {
+		err := runTargets(gba)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	run(pMap, gba)
 }
 
+// Clean compiles like Compile with --prune
+func Clean(cmd *cobra.Command, paths []string) {
+	prune = true
+	Compile(cmd, paths)
+}
+
 // run is the main entrypoint for the cli.
 func run(pMap *wt.SafePartialMap, gba *wt.BuildArgs) {
 
@@ -407,36 +721,29 @@ func run(pMap *wt.SafePartialMap, gba *wt.BuildArgs) {
 	bOpts := wt.NewBuild(gba, pMap)
 
 	err := bOpts.Run()
-	if err != nil {
+	for _, path := range bOpts.Pruned() {
+		log.Printf("Removed %s\n", path)
+	}
+	if len(report) > 0 {
+		if rerr := writeReport(bOpts); rerr != nil {
+			log.Printf("error writing report: %s\n", rerr)
+		}
+	}
+	if _, ok := err.(wt.Errors); err != nil && !ok {
 		log.Fatal(err)
 	}
 
-	// FIXME: move this to a Payload.Close() method
-
 	// Before shutting down, check that every sprite has been
 	// flushed to disk.
-	img := sync.WaitGroup{}
-	pMap.RLock()
 	// It's not currently possible to wait on Image. This is often
 	// to inline images, so it shouldn't be a factor...
-	// for _, s := range gba.Payload.Image().M {
-	// 	img.Add(1)
-	// 	err := s.Wait()
-	// 	img.Done()
-	// 	if err != nil {
-	// 		log.Printf("error writing image: %s\n", err)
-	// 	}
-	// }
-	sprites := payload.Sprite(gba.Payload)
-	sprites.ForEach(func(k string, sprite *spritewell.Sprite) {
-		img.Add(1)
-		err := sprite.Wait()
-		img.Done()
-		if err != nil {
-			log.Printf("error writing sprite: %s\n", err)
-		}
-	})
-	img.Wait()
-	pMap.RUnlock()
+	if werr := payload.Wait(gba.Payload); werr != nil {
+		log.Printf("error writing sprite: %s\n", werr)
+	}
 
+	// Files built with --keep-going are summarized once sprites
+	// are written
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -8,10 +8,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
 
+	"github.com/spf13/pflag"
 	"github.com/wellington/wellington"
 )
 
@@ -166,7 +168,7 @@ func TestStdin_sprite(t *testing.T) {
 	e := `div {
   height: 139px;
   width: 96px;
-  background: url("img/13dd5c.png") 0px 0px; }
+  background: url("img/c3518a.png") 0px 0px; }
 `
 
 	if !bytes.Contains([]byte(out), []byte(e)) {
@@ -192,7 +194,7 @@ func TestFile_comprehensive(t *testing.T) {
 	main()
 
 	e := `div {
-  background: url("img/46339f.png") 0px -139px; }
+  background: url("img/689b63.png") 0px -139px; }
 
 div {
   background-file: "../img/*.png0, 140";
@@ -247,3 +249,78 @@ func TestWatch_comprehensive(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestProcessorList(t *testing.T) {
+	defer func() { browsers, processors = "", nil }()
+
+	processors = []string{"autoprefixer"}
+	if e := []string{"autoprefixer"}; !reflect.DeepEqual(processorList(), e) {
+		t.Errorf("got: %v wanted: %v", processorList(), e)
+	}
+	browsers = "ie 11"
+	if e := []string{"autoprefixer"}; !reflect.DeepEqual(processorList(), e) {
+		t.Errorf("got: %v wanted: %v", processorList(), e)
+	}
+	processors = []string{"other"}
+	if e := []string{"other", "autoprefixer"}; !reflect.DeepEqual(processorList(), e) {
+		t.Errorf("got: %v wanted: %v", processorList(), e)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	var incs []string
+	set := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	set.StringSliceVar(&incs, "includes", nil, "")
+	set.StringSliceVarP(&incs, "", "I", nil, "")
+	if changed(set, "includes", "I") {
+		t.Fatal("flags were not set")
+	}
+	if err := set.Parse([]string{"-I", "sass"}); err != nil {
+		t.Fatal(err)
+	}
+	if !changed(set, "includes", "I") {
+		t.Error("-I was set")
+	}
+}
+
+func TestParseBuildArgs_cache(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "testparsebuildargs_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+	src := filepath.Join(tdir, "sass")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(src, "main.scss"), []byte("div { color: red; }"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tdir); err != nil {
+		t.Fatal(err)
+	}
+	// font, dir and gen keep their defaults, the working directory
+	oldFont, oldDir, oldGen := font, dir, gen
+	defer func() {
+		os.Chdir(wd)
+		font, dir, gen, buildDir, cacheDir = oldFont, oldDir, oldGen, "", ""
+	}()
+	font, dir, gen = ".", "", "."
+
+	for i, e := range []bool{false, true} {
+		buildDir, cacheDir = "build", ".wt-cache"
+		b := wellington.NewBuild(parseBuildArgs([]string{src}), wellington.NewPartialMap())
+		if err := b.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.Report().Files[0].Cached; got != e {
+			t.Errorf("build %d got cached: %t wanted: %t", i, got, e)
+		}
+	}
+}
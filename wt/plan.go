@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	wt "github.com/wellington/wellington"
+)
+
+// checkDryRun validates --dry-run
+func checkDryRun() error {
+	switch dryRun {
+	case "", "text", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown dry run format: %s, available: text, json", dryRun)
+}
+
+// writePlans writes the files each target, or gba without targets,
+// would build in the format chosen by --dry-run
+func writePlans(gba *wt.BuildArgs) error {
+	args := []*wt.BuildArgs{gba}
+	if len(targets) > 0 {
+		args = targetArgs(gba)
+	}
+
+	var plan wt.Plan
+	for _, a := range args {
+		// Without paths Compile reads from stdin
+		if len(a.Paths()) == 0 && len(a.Project) == 0 {
+			continue
+		}
+		p, err := wt.NewBuild(a, wt.NewPartialMap()).Plan()
+		if err != nil {
+			return err
+		}
+		plan = append(plan, p...)
+	}
+
+	if dryRun == "json" {
+		return plan.WriteJSON(os.Stdout)
+	}
+	return plan.WriteText(os.Stdout)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	libsass "github.com/wellington/go-libsass"
+
+	wt "github.com/wellington/wellington"
+	"github.com/wellington/wellington/handlers"
+)
+
+// checkReport validates --report, warnings are only collected for
+// reports
+func checkReport() error {
+	switch report {
+	case "":
+		return nil
+	case "json", "junit":
+		libsass.RegisterSassFunc("@warn", handlers.Warn)
+		return nil
+	}
+	return fmt.Errorf("unknown report format: %s, available: json, junit", report)
+}
+
+// writeReport combines the reports of builds and writes them in the
+// format chosen by --report
+func writeReport(builds ...*wt.Build) error {
+	r := &wt.Report{
+		Files:   []wt.FileReport{},
+		Sprites: []wt.SpriteReport{},
+	}
+	// targets may share a payload and so its sprites
+	seen := make(map[wt.SpriteReport]bool)
+	for _, b := range builds {
+		br := b.Report()
+		r.Files = append(r.Files, br.Files...)
+		for _, s := range br.Sprites {
+			if !seen[s] {
+				seen[s] = true
+				r.Sprites = append(r.Sprites, s)
+			}
+		}
+	}
+
+	// stdout may be receiving the CSS
+	var w io.Writer = os.Stderr
+	if len(reportFile) > 0 {
+		f, err := os.Create(reportFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if report == "junit" {
+		return r.WriteJUnit(w)
+	}
+	return r.WriteJSON(w)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	wt "github.com/wellington/wellington"
+	"github.com/wellington/wellington/payload"
+)
+
+// targetArgs creates BuildArgs for every selected target from the
+// global BuildArgs. Targets using the same image directories share
+// sprites, and targets writing the same manifest list the files of
+// each other. Targets are pruned together, see pruneTargets.
+func targetArgs(gba *wt.BuildArgs) []*wt.BuildArgs {
+	args := make([]*wt.BuildArgs, len(targets))
+	for i, t := range targets {
+		args[i] = t.BuildArgs(gba)
+		args[i].Prune = false
+	}
+	wt.SharePayloads(args...)
+	wt.ShareManifests(args...)
+	return args
+}
+
+// runTargets builds each selected target, reporting the outcome of
+// every target before returning.
+func runTargets(gba *wt.BuildArgs) error {
+	args := targetArgs(gba)
+
+	var failed int
+	builds := make([]*wt.Build, len(targets))
+	for i, t := range targets {
+		start := time.Now()
+		builds[i] = wt.NewBuild(args[i], wt.NewPartialMap())
+		err := builds[i].Run()
+		if err != nil {
+			failed++
+			log.Printf("target %s: %s\n", t.Name(), err)
+			continue
+		}
+		log.Printf("target %s: built in %s\n", t.Name(), time.Since(start))
+	}
+
+	for _, a := range args {
+		if err := payload.Wait(a.Payload); err != nil {
+			log.Printf("error writing sprite: %s\n", err)
+		}
+	}
+	if len(report) > 0 {
+		if err := writeReport(builds...); err != nil {
+			log.Printf("error writing report: %s\n", err)
+		}
+	}
+	if failed == 0 {
+		pruneTargets(builds)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(targets))
+	}
+	return nil
+}
+
+// watchTargets builds each selected target and starts a file watcher
+// for it, rebuilt CSS is sent to lr when set
+func watchTargets(gba *wt.BuildArgs, lr *wt.LiveReload) error {
+	args := targetArgs(gba)
+	builds := make([]*wt.Build, len(args))
+	pMaps := make([]*wt.SafePartialMap, len(args))
+	for i := range args {
+		pMaps[i] = wt.NewPartialMap()
+		builds[i] = wt.NewBuild(args[i], pMaps[i])
+		if err := builds[i].Run(); err != nil {
+			return fmt.Errorf("target %s: %s", targets[i].Name(), err)
+		}
+	}
+	pruneTargets(builds)
+
+	for i, a := range args {
+		name := targets[i].Name()
+		w, err := wt.NewWatcher(&wt.WatchOptions{
+			Paths:      a.Paths(),
+			BArgs:      a,
+			PartialMap: pMaps[i],
+			LiveReload: lr,
+			Debounce:   debounce,
+		})
+		if err != nil {
+			return fmt.Errorf("target %s: failed to start watcher: %s", name, err)
+		}
+		if err := w.Watch(); err != nil {
+			return fmt.Errorf("target %s: filewatcher error: %s", name, err)
+		}
+		log.Printf("target %s: watching %v\n", name, a.Paths())
+	}
+	return nil
+}
+
+// pruneTargets removes the stale outputs of every target when --prune
+// is set
+func pruneTargets(builds []*wt.Build) {
+	if !prune {
+		return
+	}
+	removed, err := wt.Prune(builds...)
+	for _, path := range removed {
+		log.Printf("Removed %s\n", path)
+	}
+	if err != nil {
+		log.Printf("error pruning: %s\n", err)
+	}
+}